@@ -3,6 +3,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	_ "embed"
 	"fmt"
 	"io"
@@ -10,6 +11,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 	"os"
 
 	"github.com/yueleshia/streamsurf/src"
@@ -17,13 +19,38 @@ import (
 )
 
 func help() {
-		fmt.Println(`
+		fmt.Print(`
 Possible options:
 USAGE: (Use first character or full word)
 
 streamsurf follow                    - list online status of various channels
 streamsurf open <channel> [<offset>] - see latest vods
 streamsurf vods <channel> [<offset>] - see latest vods
+    --since <date>                   - keep loading pages until <date> (e.g. 2025-01-31)
+    --all                            - load every page
+    --type <all|archive|highlight|upload|premiere> - only list this broadcast type
+streamsurf search <text>             - find channels by name, then see their VODs or follow them
+streamsurf about <channel>           - bio, followers, socials, panels and team of a channel
+streamsurf playlist <channel|vod-url> - list the qualities we can play without streamlink
+streamsurf clip <vod-url> --from <time> --to <time> [-o <file>] - download part of a VOD as MPEG-TS
+    --quality <quality>              - e.g. best, 720p, audio_only (default from player.json)
+streamsurf schedule                  - upcoming streams of every followed channel
+    --ics [<file>]                   - write them as an iCalendar file instead (stdout without <file>)
+streamsurf import --user <login> --team <name> - follow the public follows of a user and members of a team
+    -y, --yes                        - write without asking after printing what changes
+streamsurf category <name>           - top live streams and latest VODs of a category (e.g. "Just Chatting")
+streamsurf identity                  - show the client ID, user agent and device ID we send to twitch
+    --client-id <id>                 - set the client ID
+    --user-agent <user-agent>        - set the user agent
+    --scrape-client-id               - set the client ID to what twitch.tv currently uses
+    --reset-device-id                - generate a new device ID
+streamsurf doctor --schema [<channel>] - show fields twitch added or removed since our structs were written
+
+Options for every command, including the interactive mode:
+--backend=<auto|graphql|scrape>      - how to reach twitch, auto falls back to scraping
+
+Environment:
+STREAMSURF_STRICT=1                  - fail on JSON fields our structs do not have, instead of only noting them (for development)
 `)
 }
 
@@ -40,6 +67,9 @@ func main() {
 	defer pprof.StopCPUProfile()
 
 	src.Set_log_level(os.Stderr, src.DEBUG)
+	if os.Getenv("STREAMSURF_STRICT") == "1" {
+		src.STRICT_DECODE = true
+	}
 
 	{
 		list := make([]string, len(os.Args[1:]))
@@ -49,6 +79,21 @@ func main() {
 		src.L_DEBUG.Printf("Args: %s\n", strings.Join(list, " "))
 	}
 
+	{
+		args := []string{os.Args[0]}
+		for _, arg := range os.Args[1:] {
+			if mode, ok := strings.CutPrefix(arg, "--backend="); ok {
+				if err := src.TWITCH.Set_mode(mode); err != nil {
+					fmt.Fprintln(os.Stderr, err.Error())
+					os.Exit(1)
+				}
+			} else {
+				args = append(args, arg)
+			}
+		}
+		os.Args = args
+	}
+
 	var cmd string
 	if len(os.Args[1:]) <= 0 {
 		cmd = "interactive"
@@ -56,7 +101,20 @@ func main() {
 		cmd = os.Args[1]
 	}
 
-	UI.Load_config(CHANNELS)
+	// Channels followed at runtime come after the ones built in
+	if followed, err := src.Load_follow_file(); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load %s: %s\n", src.FOLLOW_FILE, err)
+		UI.Load_config(CHANNELS)
+	} else {
+		UI.Load_config(CHANNELS + "\n" + followed)
+	}
+	if err := src.Load_identity(); err != nil {
+		fmt.Fprintf(os.Stderr, "Could not load %s, using the defaults: %s\n", src.IDENTITY_FILE, err)
+	}
+	UI.Player = src.Load_player()
+	UI.Settings = src.Load_settings()
+	UI.Last_live = src.Load_last_live()
+	UI.Channel_ids = src.Load_channel_ids()
 
 	switch cmd {
 	case "interactive":
@@ -66,17 +124,17 @@ func main() {
 	case "o": fallthrough
 	case "open":
 		// @TODO: test behaviour on VOD
-		var channel string
+		var entry src.ChannelEntry
 		if len(os.Args) >= 3 {
-			channel = os.Args[2]
+			entry = cli_entry(os.Args[2])
 		}
 
-		if strings.ContainsAny(channel, "/") {
-			fmt.Fprintf(os.Stderr, "Invalid channel name %q", channel)
+		if strings.ContainsAny(entry.Login, "/") {
+			fmt.Fprintf(os.Stderr, "Invalid channel name %q", entry.Login)
 			return
 		}
 
-		sync_refresh(channel)
+		sync_refresh(entry.String())
 
 		cur := src.Video{}
 		buffer_length := len(UI.Cache.Buffer)
@@ -92,19 +150,7 @@ func main() {
 	case "f": fallthrough
 	case "follow":
 		sync_refresh(UI.Channel_list...)
-
-		// @VOLATILE: Load_config seeds the keys
-		var idx uint = 0
-		for _, pair := range UI.Follow_latest {
-			fmt.Println(pair)
-			if pair.Live.Duration > 0 {
-				UI.Follow_videos[idx] = pair.Live
-			} else {
-				UI.Follow_videos[idx] = pair.Latest
-			}
-			idx += 1
-		}
-		slices.SortFunc(UI.Follow_videos, src.Sort_videos_by_latest)
+		UI.Build_follow_videos()
 
 		choice, err := basic_menu(
 			"Follow list\n",
@@ -126,39 +172,509 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Please specify a channel to query the VODs for")
 			os.Exit(1)
 		}
-		channel := os.Args[2]
+		entry := cli_entry(os.Args[2])
+
+		var since time.Time
+		is_all := false
+		broadcast_type := ""
+		for i := 3; i < len(os.Args); i += 1 {
+			switch os.Args[i] {
+			case "--all":
+				is_all = true
+			case "--type":
+				if i + 1 >= len(os.Args) {
+					fmt.Fprintf(os.Stderr, "--type requires a broadcast type\n")
+					os.Exit(1)
+				}
+				i += 1
+				if x, err := src.Parse_broadcast_type(os.Args[i]); err != nil {
+					fmt.Fprintln(os.Stderr, err.Error())
+					os.Exit(1)
+				} else {
+					broadcast_type = x
+				}
+			case "--since":
+				if i + 1 >= len(os.Args) {
+					fmt.Fprintf(os.Stderr, "--since requires a date\n")
+					os.Exit(1)
+				}
+				i += 1
+				if x, err := parse_date(os.Args[i]); err != nil {
+					fmt.Fprintf(os.Stderr, "Invalid date %q: %s\n", os.Args[i], err)
+					os.Exit(1)
+				} else {
+					since = x
+				}
+			}
+		}
 
-		sync_refresh(channel)
-		if pair, ok := UI.Follow_latest[channel]; ok && pair.Live.Duration > 0 {
-			UI.Cache.Push(pair.Live)
+		sync_pages(entry, since, is_all, broadcast_type)
+		list_vods(entry, since, broadcast_type)
+
+	case "s": fallthrough
+	case "search":
+		if len(os.Args) < 3 {
+			fmt.Fprintf(os.Stderr, "Please specify what to search for\n")
+			os.Exit(1)
 		}
-		slices.SortFunc(UI.Cache.Buffer[UI.Cache.Start:UI.Cache.Close], src.Sort_videos_by_latest)
+		results, err := src.Graph_search(strings.Join(os.Args[2:], " "))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		UI.Search_results = results
 
-		buffer_length := len(UI.Cache.Buffer)
-		ring_length := UI.Cache.Close - UI.Cache.Start
 		choice, err := basic_menu(
-			fmt.Sprintf("VODs for %s\n", channel),
-			ring_length,
+			"Channels\n",
+			len(results),
+			"Enter a Channel: ",
+			func (out io.Writer, idx int) {
+				tui.Print_search_line(out, " | ", results[idx], UI.Is_following(results[idx].Channel.Login))
+			},
+		)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return
+		}
+		channel := results[choice].Channel.Login
+
+		fmt.Fprintf(os.Stderr, "%s: see (v)ods or (f)ollow? ", channel)
+		var action string
+		if input, err := bufio.NewReader(os.Stdin).ReadString('\n'); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return
+		} else {
+			action = strings.TrimSpace(input)
+		}
+		switch action {
+		case "v", "vods":
+			entry := UI.Entry(channel)
+			sync_pages(entry, time.Time{}, false, "")
+			list_vods(entry, time.Time{}, "")
+		case "f", "follow":
+			if err := UI.Follow(UI.Entry(channel)); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+			fmt.Printf("Following %s, see %s\n", channel, src.Must(src.Config_path(src.FOLLOW_FILE)))
+		default:
+			fmt.Fprintf(os.Stderr, "Unsupported action %q\n", action)
+		}
+
+	case "about":
+		if len(os.Args) < 3 {
+			fmt.Fprintf(os.Stderr, "Please specify a channel\n")
+			os.Exit(1)
+		}
+		entry := cli_entry(os.Args[2])
+		provider, err := entry.Get_provider()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		info, err := provider.Channel_info(entry.Login)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		for _, line := range tui.Format_about(info) {
+			fmt.Println(line)
+		}
+
+	case "playlist":
+		if len(os.Args) < 3 {
+			fmt.Fprintf(os.Stderr, "Please specify a live channel or the URL of a VOD\n")
+			os.Exit(1)
+		}
+		vid := src.Video{Url: os.Args[2]}
+		entry := src.ChannelEntry{Provider: src.DEFAULT_PROVIDER}
+		if _, err := src.Vod_id(vid); err != nil {
+			entry = cli_entry(os.Args[2])
+			vid = src.Video{Channel: entry.Login, Is_live: true}
+		}
+		provider, err := entry.Get_provider()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		variants, err := provider.Playback_variants(vid)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		for _, x := range variants {
+			fmt.Printf("%-18s %-10s %5.1f Mbps %-24s %s\n", x.Name, x.Resolution, float64(x.Bandwidth) / 1_000_000, x.Codecs, x.Url)
+		}
+
+	case "clip":
+		if len(os.Args) < 3 {
+			fmt.Fprintf(os.Stderr, "Please specify the URL of a VOD\n")
+			os.Exit(1)
+		}
+		vid := src.Video{Url: os.Args[2]}
+		id, err := src.Vod_id(vid)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+
+		var from_str, to_str, output string
+		quality := UI.Player.Quality
+		for i := 3; i < len(os.Args); i += 1 {
+			if i + 1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "%s requires a value\n", os.Args[i])
+				os.Exit(1)
+			}
+			flag, value := os.Args[i], os.Args[i + 1]
+			i += 1
+			switch flag {
+			case "--from": from_str = value
+			case "--to": to_str = value
+			case "-o", "--output": output = value
+			case "--quality": quality = value
+			default:
+				fmt.Fprintf(os.Stderr, "Unsupported option %q\n", flag)
+				os.Exit(1)
+			}
+		}
+		parse := func(flag string, value string) time.Duration {
+			if value == "" {
+				fmt.Fprintf(os.Stderr, "%s is required\n", flag)
+				os.Exit(1)
+			}
+			d, err := src.Parse_offset(value)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+			return d
+		}
+		from, to := parse("--from", from_str), parse("--to", to_str)
+		if output == "" {
+			output = tui.Clip_name(id, from, to)
+		}
+
+		provider, err := src.Get_provider(src.DEFAULT_PROVIDER)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		err = src.Clip(context.Background(), provider, vid, quality, from, to, output, func(x src.ClipProgress) {
+			fmt.Fprintf(os.Stderr, "\r%d/%d segments", x.Done, x.Total)
+		})
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		fmt.Println(output)
+
+	case "import":
+		var sources []string // e.g. "user:foo", see src.ChannelEntry.Source
+		is_yes := false
+		for i := 2; i < len(os.Args); i += 1 {
+			switch os.Args[i] {
+			case "-y", "--yes":
+				is_yes = true
+			case "--user", "--team":
+				if i + 1 >= len(os.Args) {
+					fmt.Fprintf(os.Stderr, "%s requires a value\n", os.Args[i])
+					os.Exit(1)
+				}
+				sources = append(sources, strings.TrimPrefix(os.Args[i], "--") + ":" + strings.ToLower(os.Args[i + 1]))
+				i += 1
+			default:
+				fmt.Fprintf(os.Stderr, "Unsupported option %q\n", os.Args[i])
+				os.Exit(1)
+			}
+		}
+		if len(sources) == 0 {
+			fmt.Fprintf(os.Stderr, "Please specify --user <login> or --team <name>\n")
+			os.Exit(1)
+		}
+
+		var entries []src.ChannelEntry
+		if followed, err := src.Load_follow_file(); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		} else {
+			for line := range strings.SplitSeq(followed, "\n") {
+				if strings.TrimSpace(line) != "" {
+					entries = append(entries, src.Parse_channel_entry(line))
+				}
+			}
+		}
+		built_in := map[string]bool{}
+		built_in_lines := 0
+		for line := range strings.SplitSeq(CHANNELS, "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			built_in_lines += 1
+			if entry := src.Parse_channel_entry(line); !entry.Is_category() {
+				built_in[entry.Login] = true
+			}
+		}
+
+		changes := 0
+		for _, source := range sources {
+			kind, name, _ := strings.Cut(source, ":")
+			var logins []string
+			var err error
+			if kind == "user" {
+				logins, err = src.Graph_follows(name)
+			} else {
+				logins, err = src.Graph_team(name)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not import %s: %s\n", source, err)
+				os.Exit(1)
+			}
+
+			var diff src.ImportDiff
+			entries, diff = src.Merge_import(entries, func(login string) bool { return built_in[login] }, source, logins)
+			fmt.Printf("%s: %d channels, %d added, %d removed\n", source, len(logins), len(diff.Added), len(diff.Removed))
+			for _, x := range diff.Added {
+				fmt.Printf("+ %s\n", x)
+			}
+			for _, x := range diff.Removed {
+				fmt.Printf("- %s\n", x)
+			}
+			changes += len(diff.Added) + len(diff.Removed)
+		}
+		if changes == 0 {
+			fmt.Println("Nothing to change")
+			return
+		}
+		if err := src.Check_channel_limit(built_in_lines, entries); err != nil {
+			fmt.Fprintf(os.Stderr, "Not writing %s: %s\n", src.FOLLOW_FILE, err)
+			os.Exit(1)
+		}
+
+		if !is_yes {
+			fmt.Fprintf(os.Stderr, "Write %d changes to %s? [y/N] ", changes, src.FOLLOW_FILE)
+			input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil || strings.ToLower(strings.TrimSpace(input)) != "y" {
+				fmt.Fprintln(os.Stderr, "Nothing written")
+				return
+			}
+		}
+		if err := src.Save_follow_file(entries); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+
+	case "schedule":
+		is_ics := false
+		ics_path := ""
+		for i := 2; i < len(os.Args); i += 1 {
+			if os.Args[i] == "--ics" {
+				is_ics = true
+				if i + 1 < len(os.Args) && !strings.HasPrefix(os.Args[i + 1], "-") {
+					i += 1
+					ics_path = os.Args[i]
+				}
+			} else {
+				fmt.Fprintf(os.Stderr, "Unsupported option %q\n", os.Args[i])
+				os.Exit(1)
+			}
+		}
+
+		now := time.Now()
+		segments, errs := src.Graph_schedules(UI.Schedule_channels(), now)
+		for _, err := range errs {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+			}
+		}
+		merged := src.Merge_schedules(segments)
+
+		if !is_ics {
+			for _, segment := range merged {
+				tui.Print_schedule_line(os.Stdout, " | ", segment, now)
+			}
+		} else if ics_path == "" {
+			if err := src.Write_ics(os.Stdout, merged, now); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+		} else if err := write_ics_file(ics_path, merged, now); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+
+	case "category":
+		if len(os.Args) < 3 {
+			fmt.Fprintf(os.Stderr, "Please specify a category, e.g. streamsurf category \"Just Chatting\"\n")
+			os.Exit(1)
+		}
+		// "category:<slug>" like in the channel list, otherwise a name
+		key := strings.Join(os.Args[2:], " ")
+		slug, is_slug := strings.CutPrefix(key, src.CATEGORY_PREFIX + ":")
+		if is_slug {
+			key = slug
+		}
+		queue := make(chan src.VideoPacket, tui.PACKETS_PER_REFRESH)
+		tui.Refresh_category(queue, key, !is_slug)
+		for range tui.PACKETS_PER_REFRESH {
+			if packet := <-queue; packet.Err != nil {
+				fmt.Fprintln(os.Stderr, packet.Err.Error())
+			} else {
+				UI.Add_and_update_follow(packet)
+			}
+		}
+
+		vids := append(slices.Clone(UI.Category_live[key]), UI.Category_vods[key]...)
+		if len(vids) == 0 {
+			fmt.Fprintf(os.Stderr, "Nothing found in the category %q\n", key)
+			os.Exit(1)
+		}
+		choice, err := basic_menu(
+			fmt.Sprintf("Live streams and VODs for %s\n", vids[0].Game),
+			len(vids),
 			"Enter a Video: ",
 			func (out io.Writer, idx int) {
-				reversed_idx := ring_length - ((UI.Cache.Close - idx) % buffer_length)
-				vid := UI.Cache.Buffer[reversed_idx]
-				tui.Print_formatted_line(out, " | ", vid)
+				tui.Print_formatted_line(out, " | ", vids[idx])
 			},
 		)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s\n", err)
 			return
 		}
+		play(vids[choice])
+
+	case "identity":
+		args := os.Args[2:]
+		for i := 0; i < len(args); i += 1 {
+			var update func(*src.Identity)
+			switch args[i] {
+			case "--client-id", "--user-agent":
+				if i + 1 >= len(args) {
+					fmt.Fprintf(os.Stderr, "%s requires a value\n", args[i])
+					os.Exit(1)
+				}
+				flag, value := args[i], args[i + 1]
+				i += 1
+				update = func(x *src.Identity) {
+					if flag == "--client-id" {
+						x.Client_id = value
+					} else {
+						x.User_agent = value
+					}
+				}
+			case "--scrape-client-id":
+				client_id, err := src.Scrape_client_id()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Could not scrape the client ID: %s\n", err)
+					os.Exit(1)
+				}
+				update = func(x *src.Identity) { x.Client_id = client_id }
+			case "--reset-device-id":
+				update = func(x *src.Identity) { x.Device_id = "" }
+			default:
+				fmt.Fprintf(os.Stderr, "Unsupported option %q\n", args[i])
+				os.Exit(1)
+			}
+			src.Must1(src.Update_identity(update))
+		}
+		// Generates a device ID if we reset it
+		src.Must1(src.Load_identity())
+
+		id := src.Get_identity()
+		fmt.Printf("Client-Id:  %s\n", id.Client_id)
+		fmt.Printf("User-Agent: %s\n", id.User_agent)
+		fmt.Printf("Device-ID:  %s\n", id.Device_id)
+		if id.Integrity_token != "" {
+			fmt.Printf("Client-Integrity expires %s\n", id.Integrity_expiration.Format(time.RFC3339))
+		}
 
-		vid := UI.Cache.Buffer[(UI.Cache.Close - choice - 1) % buffer_length]
-		play(vid)
+	case "doctor":
+		var channel string
+		is_schema := false
+		for _, arg := range os.Args[2:] {
+			if arg == "--schema" {
+				is_schema = true
+			} else if !strings.HasPrefix(arg, "-") {
+				channel = arg
+			}
+		}
+		if !is_schema {
+			fmt.Fprintf(os.Stderr, "Nothing to check, try: streamsurf doctor --schema [<channel>]\n")
+			os.Exit(1)
+		}
+		if channel == "" {
+			if len(UI.Channel_list) == 0 {
+				fmt.Fprintf(os.Stderr, "Please specify a channel to check against\n")
+				os.Exit(1)
+			}
+			channel = src.Parse_channel_entry(UI.Channel_list[0]).Login
+		}
+		doctor_schema(channel)
 
 	default:
 		fmt.Fprintf(os.Stderr, "Unsupported command %q\n", cmd)
 	}
 }
 
+// Let the user pick a video of `entry` that we have in the cache and play it
+func list_vods(entry src.ChannelEntry, since time.Time, broadcast_type string) {
+	channel := entry.Login
+	var vids []src.Video
+	if live, ok := UI.Live_video(channel); ok {
+		vids = append(vids, live)
+	}
+	for _, vid := range UI.Cache.As_slice() {
+		if vid.Channel == channel && !vid.Start_time.Before(since) && !tui.Is_filtered_out(entry, broadcast_type, vid) {
+			vids = append(vids, vid)
+		}
+	}
+	slices.SortFunc(vids, src.Sort_videos_by_latest)
+
+	choice, err := basic_menu(
+		fmt.Sprintf("VODs for %s\n", channel),
+		len(vids),
+		"Enter a Video: ",
+		func (out io.Writer, idx int) {
+			tui.Print_formatted_line(out, " | ", vids[idx])
+		},
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return
+	}
+
+	vid := vids[choice]
+	play(vid)
+}
+
+// An explicit provider wins, otherwise use whatever the channel list says
+func cli_entry(arg string) src.ChannelEntry {
+	if strings.Contains(arg, ":") {
+		return src.Parse_channel_entry(arg)
+	}
+	return UI.Entry(arg)
+}
+
+// Write then rename so that a calendar app never reads half a file
+func write_ics_file(path string, segments []src.ScheduleSegment, now time.Time) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = src.Write_ics(file, segments, now)
+	if close_err := file.Close(); err == nil {
+		err = close_err
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
+
 func sync_refresh(channels ...string) {
 	job_count := len(channels) * tui.PACKETS_PER_REFRESH
 	vid_chan := make(chan src.VideoPacket, job_count)
@@ -170,10 +686,106 @@ func sync_refresh(channels ...string) {
 			UI.Add_and_update_follow(packet)
 		}
 	}
+	UI.Save_last_live()
+	UI.Save_channel_ids()
+}
+
+// Keep requesting pages until we pass `since`, or until there are no pages
+// left if `is_all`. A `broadcast_type` other than "" pages through only that
+// type of VOD.
+func sync_pages(entry src.ChannelEntry, since time.Time, is_all bool, broadcast_type string) {
+	sync_refresh(entry.String())
+	if !is_all && since.IsZero() && broadcast_type == "" {
+		return
+	}
+
+	var provider src.Provider
+	if x, err := entry.Get_provider(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return
+	} else {
+		provider = x
+	}
+
+	// The refresh paged through every type, start over with just ours
+	if broadcast_type != "" {
+		UI.Channel_filter = broadcast_type
+		delete(UI.Channel_next, entry.Login)
+		packet := provider.Vods(entry.Login, "", broadcast_type)
+		if packet.Err != nil {
+			fmt.Fprintln(os.Stderr, packet.Err.Error())
+			return
+		}
+		UI.Add_and_update_follow(packet)
+		if !is_all && since.IsZero() {
+			return
+		}
+	}
+
+	for {
+		oldest := time.Now()
+		for _, vid := range UI.Cache.As_slice() {
+			if vid.Channel == entry.Login && vid.Start_time.Before(oldest) {
+				oldest = vid.Start_time
+			}
+		}
+		next := UI.Channel_next[entry.Login]
+		if next == "" || (!is_all && oldest.Before(since)) {
+			return
+		}
+
+		packet := provider.Vods(entry.Login, next, broadcast_type)
+		if packet.Err != nil {
+			fmt.Fprintln(os.Stderr, packet.Err.Error())
+			return
+		}
+		UI.Add_and_update_follow(packet)
+	}
+}
+
+// Run every decoder once, then report how the JSON differed from our structs
+func doctor_schema(channel string) {
+	report := func(backend string, err error) {
+		if _, is_missing := err.(src.ErrMissing); err != nil && !is_missing {
+			fmt.Fprintf(os.Stderr, "%s%s: %s%s\n", src.ANSI_FG_RED, backend, err, src.ANSI_RESET)
+		}
+	}
+	for _, packet := range (src.TwitchGraph{}).Refresh([]string{channel}) {
+		report("graphql", packet.Err)
+	}
+	_, err := src.Graph_channel_info(channel)
+	report("graphql", err)
+	report("scrape", src.TwitchScrape{}.Vods(channel, "", "").Err)
+	report("scrape", src.TwitchScrape{}.Live_status(channel).Err)
+
+	drifts := src.Drift_report()
+	if len(drifts) == 0 {
+		fmt.Println("No schema drift")
+	}
+	for _, drift := range drifts {
+		fmt.Println(drift.Name)
+		for _, path := range drift.Appeared {
+			fmt.Printf("  %s+ %s%s\n", src.ANSI_FG_GREEN, path, src.ANSI_RESET)
+		}
+		for _, path := range drift.Disappeared {
+			fmt.Printf("  %s- %s%s\n", src.ANSI_FG_RED, path, src.ANSI_RESET)
+		}
+	}
+}
+
+func parse_date(s string) (time.Time, error) {
+	if x, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return x, nil
+	}
+	return time.Parse(time.RFC3339, s)
 }
 
 func play(vid src.Video) {
 	tui.Print_formatted_line(os.Stderr, " | ", vid)
+	if err := src.Check_playable(vid); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return
+	}
 	stdin := bufio.NewReader(os.Stdin)
 	if vid.Is_live {
 		fmt.Fprint(os.Stderr, "Start time (e.g. 1:00:00) (leave blank for live): ")
@@ -181,22 +793,35 @@ func play(vid src.Video) {
 		fmt.Fprint(os.Stderr, "Start time (e.g. 1:00:00): ")
 	}
 
-	//src.Must1(src.Run(nil, os.Stdout, "streamlink", "https://www.twitch.tv/" + vid.Channel))
 	var start_time string
 	if input, err := stdin.ReadString('\n'); err != nil {
-		fmt.Fprint(os.Stderr, "%s\n", err)
+		fmt.Fprintf(os.Stderr, "%s\n", err)
 	} else {
 		start_time = input[:len(input) - len("\n")]
 	}
 
-	if start_time == "" {
-		if vid.Is_live {
-			src.Must1(src.Run(nil, os.Stdout, "streamlink", "https://www.twitch.tv/" + vid.Channel))
-		} else {
-			src.Must1(src.Run(nil, os.Stdout, "streamlink", vid.Url))
-		}
-	} else {
-		src.Must1(src.Run(nil, os.Stdout, "streamlink", "--hls-start-offset", start_time, vid.Url))
+	provider, err := UI.Entry(vid.Channel).Get_provider()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return
+	}
+
+	if offset := src.Start_offset(UI.Player, vid, start_time); offset != start_time {
+		fmt.Fprintf(os.Stderr, "Skipping muted audio, starting at %s\n", offset)
+		start_time = offset
+	}
+
+	cmd, err := src.Player_command(context.Background(), UI.Player, provider, vid, start_time)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return
+	}
+	src.L_DEBUG.Printf("%s %s", cmd.Path, strings.Join(cmd.Args[1:], " "))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", cmd.Args[0], err)
 	}
 }
 
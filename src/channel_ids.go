@@ -0,0 +1,20 @@
+package src
+
+// User IDs of every channel we refreshed, including the built-in ones, so that
+// a channel renamed between two sessions is still found by its ID
+
+const CHANNEL_IDS_FILE = "channel_ids.json"
+
+// By login
+func Load_channel_ids() map[string]string {
+	table := map[string]string{}
+	if err := Load_config_file(CHANNEL_IDS_FILE, &table); err != nil {
+		L_ERROR.Printf("Could not load %s: %s", CHANNEL_IDS_FILE, err)
+		return map[string]string{}
+	}
+	return table
+}
+
+func Save_channel_ids(table map[string]string) error {
+	return Save_config_file(CHANNEL_IDS_FILE, table)
+}
@@ -0,0 +1,304 @@
+package src
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Downloads only the media segments of a VOD that cover a time range, then
+// concatenates them. MPEG-TS segments can be joined byte for byte, so there is
+// no re-encoding and no ffmpeg.
+
+const CLIP_WORKERS = 4
+
+// One #EXTINF entry of a media playlist
+type Segment struct {
+	Url      string
+	Index    int           // In the media playlist
+	Start    time.Duration // From the start of the VOD
+	Duration time.Duration
+	Is_muted bool // Twitch swaps in a silent copy of muted segments, e.g. 12-muted.ts
+}
+
+// e.g. "1:02:00", "62:00", "3720", or a go duration like "1h2m"
+func Parse_offset(text string) (time.Duration, error) {
+	text = strings.TrimSpace(text)
+	if d, err := time.ParseDuration(text); err == nil {
+		return d, nil
+	}
+	parts := strings.Split(text, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("Invalid time %q, expected hh:mm:ss", text)
+	}
+	var total time.Duration
+	for _, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("Invalid time %q, expected hh:mm:ss", text)
+		}
+		total = total * 60 + time.Duration(n * float64(time.Second))
+	}
+	return total, nil
+}
+
+// The inverse of Parse_offset, e.g. "1:02:00"
+func Format_offset(offset time.Duration) string {
+	seconds := int(offset.Seconds())
+	return fmt.Sprintf("%d:%02d:%02d", seconds / 3600, seconds / 60 % 60, seconds % 60)
+}
+
+// For when the VOD metadata did not list the muted ranges, see skip_muted_in_playlist
+func Muted_ranges(segments []Segment) []MutedRange {
+	var ranges []MutedRange
+	for _, x := range segments {
+		if !x.Is_muted {
+			continue
+		}
+		if last := len(ranges) - 1; last >= 0 && ranges[last].Offset + ranges[last].Duration == x.Start {
+			ranges[last].Duration += x.Duration
+		} else {
+			ranges = append(ranges, MutedRange{Offset: x.Start, Duration: x.Duration})
+		}
+	}
+	return ranges
+}
+
+func Parse_media_playlist(input io.Reader, base *url.URL) ([]Segment, error) {
+	var segments []Segment
+	var position time.Duration
+	var pending time.Duration = -1
+
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 64 * 1024), 1024 * 1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if info, ok := strings.CutPrefix(line, "#EXTINF:"); ok {
+			seconds, _, _ := strings.Cut(info, ",")
+			x, err := strconv.ParseFloat(seconds, 64)
+			if err != nil {
+				return segments, fmt.Errorf("Invalid #EXTINF %q", line)
+			}
+			pending = time.Duration(x * float64(time.Second))
+		} else if line != "" && !strings.HasPrefix(line, "#") && pending >= 0 {
+			ref, err := url.Parse(line)
+			if err != nil {
+				return segments, err
+			}
+			is_muted := strings.HasSuffix(ref.Path, "-muted.ts")
+			segments = append(segments, Segment{Url: base.ResolveReference(ref).String(), Index: len(segments), Start: position, Duration: pending, Is_muted: is_muted})
+			position += pending
+			pending = -1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return segments, err
+	}
+	if len(segments) == 0 {
+		return segments, ErrMissing{message: "No segments in the media playlist"}
+	}
+	return segments, nil
+}
+
+// The clip starts at the start of the segment that `from` falls in, so it can
+// be a few seconds longer than asked for
+func Segments_in_range(segments []Segment, from time.Duration, to time.Duration) []Segment {
+	var ret []Segment
+	for _, x := range segments {
+		if x.Start + x.Duration > from && x.Start < to {
+			ret = append(ret, x)
+		}
+	}
+	return ret
+}
+
+func Fetch_media_playlist(variant Variant) ([]Segment, error) {
+	base, err := url.Parse(variant.Url)
+	if err != nil {
+		return nil, err
+	}
+	body, err := Request(context.TODO(), "GET", nil, nil, variant.Url, "media-" + variant.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return Parse_media_playlist(body, base)
+}
+
+type ClipProgress struct {
+	Path  string
+	Done  int
+	Total int
+	Err   error // Set on the last report if the clip failed
+}
+
+// Segments are kept in `output` + ".parts" until every one of them is there,
+// so running the same clip again only downloads what is missing. The parts are
+// named by their index in the media playlist.
+func Clip(ctx context.Context, provider Provider, video Video, quality string, from time.Duration, to time.Duration, output string, progress func(ClipProgress)) error {
+	if to <= from {
+		return fmt.Errorf("The end of the clip (%s) must be after its start (%s)", to, from)
+	}
+	if err := Check_playable(video); err != nil {
+		return err
+	}
+	variants, err := provider.Playback_variants(video)
+	if err != nil {
+		return err
+	}
+	variant, err := Pick_variant(variants, quality)
+	if err != nil {
+		return err
+	}
+	segments, err := Fetch_media_playlist(variant)
+	if err != nil {
+		return err
+	}
+	segments = Segments_in_range(segments, from, to)
+	if len(segments) == 0 {
+		return ErrMissing{message: fmt.Sprintf("Nothing between %s and %s in %s", from, to, video.Url)}
+	}
+
+	parts_dir := output + ".parts"
+	if err := prepare_parts(parts_dir, fmt.Sprintf("%s %s %s-%s\n", video.Url, variant.Name, from, to)); err != nil {
+		return err
+	}
+	part_path := func(i int) string {
+		return filepath.Join(parts_dir, fmt.Sprintf("%06d.ts", segments[i].Index))
+	}
+
+	var mutex sync.Mutex
+	done := 0
+	report := func() {
+		mutex.Lock()
+		done += 1
+		x := ClipProgress{Path: output, Done: done, Total: len(segments)}
+		mutex.Unlock()
+		progress(x)
+	}
+
+	jobs := make(chan int)
+	errs := make(chan error, len(segments))
+	var wait sync.WaitGroup
+	for range CLIP_WORKERS {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for i := range jobs {
+				if err := download_segment(ctx, segments[i].Url, part_path(i)); err != nil {
+					errs <- err
+				} else {
+					report()
+				}
+			}
+		}()
+	}
+	for i := range segments {
+		jobs <- i
+	}
+	close(jobs)
+	wait.Wait()
+	close(errs)
+	if err := errors.Join(collect(errs)...); err != nil {
+		return err
+	}
+
+	if err := concatenate(output, len(segments), part_path); err != nil {
+		return err
+	}
+	return os.RemoveAll(parts_dir)
+}
+
+// `stamp` says which VOD, quality and range the parts are of. Parts of
+// another clip are of no use, so those are deleted.
+func prepare_parts(dir string, stamp string) error {
+	path := filepath.Join(dir, "clip.txt")
+	if data, err := os.ReadFile(path); err == nil && string(data) == stamp {
+		return nil
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(stamp), 0o644)
+}
+
+func collect[T any](channel chan T) []T {
+	var ret []T
+	for x := range channel {
+		ret = append(ret, x)
+	}
+	return ret
+}
+
+// Skips segments we already have. Partial downloads never have the final
+// name, so a part that exists is complete.
+func download_segment(ctx context.Context, target string, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	body, err := Request(ctx, "GET", nil, nil, target, "segment-" + target)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return write_atomic(path, body)
+}
+
+func concatenate(output string, count int, part_path func(int) string) error {
+	tmp := output + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	for i := range count {
+		if err := append_file(file, part_path(i)); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, output)
+}
+
+func append_file(output io.Writer, path string) error {
+	part, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+	_, err = io.Copy(output, part)
+	return err
+}
+
+func write_atomic(path string, input io.Reader) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, input); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
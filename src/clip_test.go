@@ -0,0 +1,146 @@
+package src
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	a "github.com/yueleshia/streamsurf/src/testify"
+)
+
+//run: go test -v
+
+func TestParseOffset(t *testing.T) {
+	parse := func(text string) time.Duration {
+		d, err := Parse_offset(text)
+		if err != nil {
+			return -1
+		}
+		return d
+	}
+	a.AssertEqual(t, time.Hour + 2 * time.Minute, parse("1:02:00"))
+	a.AssertEqual(t, 62 * time.Minute, parse("62:00"))
+	a.AssertEqual(t, 90 * time.Second, parse("90"))
+	a.AssertEqual(t, time.Hour + 30 * time.Second, parse("1h30s"))
+	a.AssertEqual(t, time.Duration(-1), parse("1:2:3:4"))
+	a.AssertEqual(t, time.Duration(-1), parse("abc"))
+}
+
+func TestMediaPlaylist(t *testing.T) {
+	playlist := `#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXTINF:10.000,
+0.ts
+#EXTINF:10.000,
+1.ts
+#EXTINF:4.500,
+https://other.example.com/2.ts
+#EXT-X-ENDLIST
+`
+	base, _ := url.Parse("https://example.com/vod/chunked/index-dvr.m3u8")
+	segments, err := Parse_media_playlist(strings.NewReader(playlist), base)
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, []Segment{
+		{Url: "https://example.com/vod/chunked/0.ts", Index: 0, Start: 0, Duration: 10 * time.Second},
+		{Url: "https://example.com/vod/chunked/1.ts", Index: 1, Start: 10 * time.Second, Duration: 10 * time.Second},
+		{Url: "https://other.example.com/2.ts", Index: 2, Start: 20 * time.Second, Duration: 4500 * time.Millisecond},
+	}, segments)
+
+	a.AssertEqual(t, segments[1:3], Segments_in_range(segments, 15 * time.Second, 21 * time.Second))
+	a.AssertEqual(t, segments[0:1], Segments_in_range(segments, 0, 10 * time.Second))
+}
+
+func TestMutedSegments(t *testing.T) {
+	playlist := `#EXTM3U
+#EXTINF:10.000,
+0.ts
+#EXTINF:10.000,
+1-muted.ts
+#EXTINF:10.000,
+2-muted.ts
+#EXTINF:10.000,
+3-unmuted.ts
+#EXTINF:10.000,
+4-muted.ts
+#EXT-X-ENDLIST
+`
+	base, _ := url.Parse("https://example.com/vod/chunked/index-dvr.m3u8")
+	segments, err := Parse_media_playlist(strings.NewReader(playlist), base)
+	a.AssertEqual(t, nil, err)
+	muted := Muted_ranges(segments)
+	a.AssertEqual(t, []MutedRange{
+		{Offset: 10 * time.Second, Duration: 20 * time.Second},
+		{Offset: 40 * time.Second, Duration: 10 * time.Second},
+	}, muted)
+
+	video := Video{Muted: muted}
+	a.AssertEqual(t, 30 * time.Second, video.Total_muted())
+	a.AssertEqual(t, 5 * time.Second, video.Skip_muted(5 * time.Second))
+	a.AssertEqual(t, 30 * time.Second, video.Skip_muted(15 * time.Second))
+	a.AssertEqual(t, 30 * time.Second, video.Skip_muted(30 * time.Second))
+
+	config := DEFAULT_PLAYER
+	video.Muted = []MutedRange{{Offset: time.Hour, Duration: 90 * time.Second}, {Offset: time.Hour + 90 * time.Second, Duration: time.Minute}}
+	a.AssertEqual(t, "1:02:30", Start_offset(config, video, "1:00:30"))
+	a.AssertEqual(t, "59:00", Start_offset(config, video, "59:00"))
+	a.AssertEqual(t, "", Start_offset(config, video, ""))
+	config.Skip_muted = false
+	a.AssertEqual(t, "1:00:30", Start_offset(config, video, "1:00:30"))
+
+	// Scraped VODs fall back to the playlist
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(playlist))
+	}))
+	defer server.Close()
+	variant := Variant{Url: server.URL + "/index-dvr.m3u8"}
+	a.AssertEqual(t, "0:00:30", skip_muted_in_playlist(DEFAULT_PLAYER, Video{Backend: "scrape"}, variant, "15"))
+	a.AssertEqual(t, "15", skip_muted_in_playlist(DEFAULT_PLAYER, Video{Backend: "graphql"}, variant, "15"))
+}
+
+func TestResumableDownload(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_, _ = w.Write([]byte(strings.TrimPrefix(r.URL.Path, "/")))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	part_path := func(i int) string { return filepath.Join(dir, []string{"a", "b", "c"}[i]) }
+	// As if an earlier run was interrupted after the second segment
+	a.AssertEqual(t, nil, os.WriteFile(part_path(1), []byte("B"), 0o644))
+
+	for i, name := range []string{"a", "b", "c"} {
+		a.AssertEqual(t, nil, download_segment(context.Background(), server.URL + "/" + name, part_path(i)))
+	}
+	a.AssertEqual(t, int32(2), hits.Load())
+
+	output := filepath.Join(dir, "out.ts")
+	a.AssertEqual(t, nil, concatenate(output, 3, part_path))
+	data, err := os.ReadFile(output)
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, "aBc", string(data))
+}
+
+func TestPartsStamp(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out.ts.parts")
+	a.AssertEqual(t, nil, prepare_parts(dir, "vod 720p30 0s-1m0s\n"))
+	a.AssertEqual(t, nil, os.WriteFile(filepath.Join(dir, "000003.ts"), []byte("x"), 0o644))
+
+	// Same clip, the part is kept
+	a.AssertEqual(t, nil, prepare_parts(dir, "vod 720p30 0s-1m0s\n"))
+	_, err := os.Stat(filepath.Join(dir, "000003.ts"))
+	a.AssertEqual(t, nil, err)
+
+	// Another range starts over
+	a.AssertEqual(t, nil, prepare_parts(dir, "vod 720p30 30s-1m0s\n"))
+	_, err = os.Stat(filepath.Join(dir, "000003.ts"))
+	a.AssertEqual(t, true, os.IsNotExist(err))
+}
@@ -0,0 +1,171 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Files the user may edit live in the OS config directory, e.g. ~/.config/streamsurf
+func Config_path(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "streamsurf", name), nil
+}
+
+// Only creates the directory once we have something to write
+func writable_config_path(name string) (string, error) {
+	path, err := Config_path(name)
+	if err != nil {
+		return "", err
+	}
+	return path, os.MkdirAll(filepath.Dir(path), 0o755)
+}
+
+// `out` should hold the defaults, which are left as they are if the file does
+// not exist. Nothing is written, so only a change creates the file.
+func Load_config_file(name string, out any) error {
+	path, err := Config_path(name)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func Save_config_file(name string, value any) error {
+	path, err := writable_config_path(name)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Channels followed at runtime, e.g. from search, in the same format as the
+// channel list that is embedded at build time
+const FOLLOW_FILE = "channel_list.txt"
+
+// An empty string if we have not followed anything at runtime yet
+func Load_follow_file() (string, error) {
+	path, err := Config_path(FOLLOW_FILE)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return string(data), err
+}
+
+func Append_follow_line(line string) error {
+	return Append_config_line(FOLLOW_FILE, line)
+}
+
+// What disappeared from twitch, see tui.UIState.Track_changes
+const VOD_LOG_FILE = "vod_changes.log"
+
+func Append_config_line(name string, line string) error {
+	path, err := writable_config_path(name)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(line + "\n"); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Rewrites the whole file, e.g. after an import removed lines
+func Save_follow_file(entries []ChannelEntry) error {
+	path, err := writable_config_path(FOLLOW_FILE)
+	if err != nil {
+		return err
+	}
+	var builder strings.Builder
+	for _, x := range entries {
+		builder.WriteString(x.String() + "\n")
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(builder.String()), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// `built_in` is how many lines the embedded channel list has
+func Check_channel_limit(built_in int, entries []ChannelEntry) error {
+	if total := built_in + len(entries); total > MAX_CHANNELS {
+		return fmt.Errorf("That would make %d channels, but we only have room for %d", total, MAX_CHANNELS)
+	}
+	return nil
+}
+
+type ImportDiff struct {
+	Added   []string
+	Removed []string
+}
+
+// `file` is what FOLLOW_FILE holds, and `is_built_in` says if a login is in the
+// embedded channel list. Importing the same `source` again drops the channels
+// it no longer lists, but never touches lines that were added by hand.
+func Merge_import(file []ChannelEntry, is_built_in func(string) bool, source string, logins []string) ([]ChannelEntry, ImportDiff) {
+	var diff ImportDiff
+	wanted := map[string]bool{}
+	for _, x := range logins {
+		wanted[x] = true
+	}
+
+	merged := make([]ChannelEntry, 0, len(file) + len(logins))
+	have := map[string]bool{}
+	for _, x := range file {
+		if x.Source == source && !wanted[x.Login] {
+			diff.Removed = append(diff.Removed, x.Login)
+			continue
+		}
+		if !x.Is_category() {
+			have[x.Login] = true
+		}
+		merged = append(merged, x)
+	}
+	for _, login := range logins {
+		if have[login] || is_built_in(login) {
+			continue
+		}
+		have[login] = true
+		merged = append(merged, ChannelEntry{Provider: DEFAULT_PROVIDER, Login: login, Source: source})
+		diff.Added = append(diff.Added, login)
+	}
+	return merged, diff
+}
+
+// Follows renamed channels by their new login. `renames` is by the login in
+// the entry. Returns how many entries changed.
+func Rewrite_entries(entries []ChannelEntry, renames map[string]string) int {
+	changed := 0
+	for i, x := range entries {
+		if login, ok := renames[x.Login]; ok && !x.Is_category() {
+			entries[i].Login = login
+			changed += 1
+		}
+	}
+	return changed
+}
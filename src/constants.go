@@ -1,18 +1,26 @@
 package src
 
 import (
+	"fmt"
+	"strings"
 	"time"
 )
 
 // Customise runtime behaviour
 const IS_CLEAR = false // Remove old local files
 const IS_LOCAL = false // Use local files on second run instead of issuing network requests
+var STRICT_DECODE = false // Fail on JSON fields we do not know about, instead of only reporting them (for development), set with STREAMSURF_STRICT=1
 
-var CLIENT_ID = "ue6666qo983tsx6so1t0vnawi233wa" // old: kimne78kx3ncx6brgo4mv6wki5h1ko
+// Defaults, Load_identity() overrides these with what is in the config directory
+var CLIENT_ID = "ue6666qo983tsx6so1t0vnawi233wa"
 var USER_AGENT = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/112.0.0.0 Safari/537.36"
 
 const RING_QUEUE_SIZE int = 10000
 const PAGE_SIZE = 20
+// Lines of the channel list, built in and FOLLOW_FILE together, that the cache
+// has room for the first page of
+const MAX_CHANNELS = RING_QUEUE_SIZE / PAGE_SIZE
+var BATCH_SIZE = 20 // Channels per GraphQL request when refreshing, see Settings.Batch_size
 
 const ANSI_FG_RED = "\x1b[31m"
 const ANSI_FG_GREEN = "\x1b[32m"
@@ -23,22 +31,206 @@ type VideoPacket struct {
 	Vids []Video
 	Live bool
 	Err  error
+
+	// Pagination, only VOD packets from GraphQL fill these in
+	Channel string
+	Cursor  string // The cursor this page was requested with, "" for the first page
+	Next    string // The cursor of the following page, "" when there are no more pages
+	// The pages list every VOD, so one missing from its page is gone. Scraped
+	// pages only hold the latest few.
+	Complete bool
+
+	Backend string // Which backend of the provider answered, e.g. "graphql"
+	User_id string // Of Channel, "" if the backend does not say
+	Broadcast_type string // The filter the page was requested with, "" for all types
+
+	// Slug or name of the category for packets from Graph_category. The live packet
+	// then holds every live stream instead of a single video.
+	Category string
+}
+
+func (self *VideoPacket) Stamp_backend(name string) {
+	self.Backend = name
+	for i := range self.Vids {
+		self.Vids[i].Backend = name
+	}
 }
 
 type Chapter struct {
 	Name     string
 	Position time.Duration
+	Duration time.Duration
 }
 
 type Video struct {
-	Title         string
-	Channel       string
-	Thumbnail_URL []string
-	Start_time    time.Time
-	Duration      time.Duration
-	Is_live       bool
-	Url           string
-	Chapters      []Chapter
+	Title          string
+	Channel        string
+	Thumbnail_URL  []string
+	Start_time     time.Time
+	Duration       time.Duration
+	Is_live        bool
+	Url            string
+	Chapters       []Chapter
+	Backend        string
+	Broadcast_type string // One of BROADCAST_*, "" if unknown or live
+
+	// Not every backend knows these, zero values mean unknown
+	Display_name   string
+	Avatar_URL     string
+	Game           string
+	Language       string // Broadcaster language, e.g. "en"
+	Tags           []string
+	Description    string
+	Viewers        int // Current viewers when live
+	Peak_viewers   int // Highest Viewers we have seen during this stream
+	View_count     int // Total views of a VOD
+	Muted          []MutedRange // Audio twitch muted for copyright, sorted by Offset
+	Restriction    string // e.g. RESTRICTION_SUB_ONLY, "" if anyone can watch
+	Status         string // e.g. VIDEO_STATUS_RECORDED, "" if the provider does not say
+	Raided         string // Login of the channel the stream raided when it ended, set by the follow screen
+	Change         int    // One of VIDEO_*, whether it is still listed
+	Old_title      string // The first title we saw, if the streamer retitled it since
+	Stream_type    string // One of STREAM_*, "" for a normal live broadcast and for VODs
+	Last_live      LastBroadcast // Of the channel, for rows of offline channels without VODs
+}
+
+// Twitch still calls a live stream "live" when it plays something recorded
+const (
+	STREAM_RERUN       = "rerun"
+	STREAM_PREMIERE    = "premiere"
+	STREAM_WATCH_PARTY = "watch_party"
+)
+
+// "live" and unknown types are a normal live broadcast
+func Parse_stream_type(name string) string {
+	switch ty := strings.ToLower(name); ty {
+	case STREAM_RERUN, STREAM_PREMIERE, STREAM_WATCH_PARTY: return ty
+	}
+	return ""
+}
+
+// e.g. "watch party"
+func Stream_type_name(ty string) string {
+	return strings.ReplaceAll(ty, "_", " ")
+}
+
+func Is_rerun(video Video) bool {
+	return video.Is_live && video.Stream_type == STREAM_RERUN
+}
+
+const (
+	VIDEO_LISTED = iota
+	VIDEO_REMOVED // Deleted, e.g. by the streamer
+	VIDEO_EXPIRED // Past broadcasts fall off the end of the list once twitch stops keeping them
+)
+
+func Video_change_name(change int) string {
+	switch change {
+	case VIDEO_REMOVED: return "removed"
+	case VIDEO_EXPIRED: return "expired"
+	}
+	return "listed"
+}
+
+type MutedRange struct {
+	Offset   time.Duration
+	Duration time.Duration
+}
+
+func (self Video) Total_muted() time.Duration {
+	var total time.Duration
+	for _, x := range self.Muted {
+		total += x.Duration
+	}
+	return total
+}
+
+// Moves `offset` to the end of the muted range it is in, if any. Ranges that
+// touch are skipped together.
+func (self Video) Skip_muted(offset time.Duration) time.Duration {
+	for _, x := range self.Muted {
+		if x.Offset <= offset && offset < x.Offset + x.Duration {
+			offset = x.Offset + x.Duration
+		}
+	}
+	return offset
+}
+
+// Twitch's BroadcastType enum
+const (
+	BROADCAST_ARCHIVE   = "ARCHIVE"   // Past broadcasts
+	BROADCAST_HIGHLIGHT = "HIGHLIGHT"
+	BROADCAST_UPLOAD    = "UPLOAD"
+	BROADCAST_PREMIERE  = "PAST_PREMIERE"
+)
+
+// From what users type, e.g. "archive" or "archives". "all" gives "".
+func Parse_broadcast_type(name string) (string, error) {
+	switch strings.TrimSuffix(strings.ToLower(name), "s") {
+	case "all", "":      return "", nil
+	case "archive":      return BROADCAST_ARCHIVE, nil
+	case "highlight":    return BROADCAST_HIGHLIGHT, nil
+	case "upload":       return BROADCAST_UPLOAD, nil
+	case "premiere":     return BROADCAST_PREMIERE, nil
+	}
+	return "", fmt.Errorf("Unknown broadcast type %q, expected all, archive, highlight, upload, or premiere", name)
+}
+
+// The inverse of Parse_broadcast_type
+func Broadcast_type_name(broadcast_type string) string {
+	switch broadcast_type {
+	case "":                  return "all"
+	case BROADCAST_ARCHIVE:   return "archive"
+	case BROADCAST_HIGHLIGHT: return "highlight"
+	case BROADCAST_UPLOAD:    return "upload"
+	case BROADCAST_PREMIERE:  return "premiere"
+	}
+	return strings.ToLower(broadcast_type)
+}
+
+const (
+	RESTRICTION_SUB_ONLY = "SUB_ONLY_LIVE"
+
+	VIDEO_STATUS_RECORDED  = "RECORDED"
+	VIDEO_STATUS_RECORDING = "RECORDING" // The archive of a stream that is still live
+)
+
+// e.g. "subscribers only"
+func Restriction_name(restriction string) string {
+	switch restriction {
+	case RESTRICTION_SUB_ONLY: return "subscribers only"
+	}
+	return strings.ReplaceAll(strings.ToLower(restriction), "_", " ")
+}
+
+// Why the player would fail on this video, nil if it should play
+func Check_playable(video Video) error {
+	if video.Is_live {
+		return nil
+	}
+	if video.Change != VIDEO_LISTED {
+		return fmt.Errorf("Cannot play %q, it is no longer on twitch (%s)", video.Title, Video_change_name(video.Change))
+	}
+	if video.Restriction != "" {
+		return fmt.Errorf("Cannot play %q, the VOD is restricted to %s", video.Title, Restriction_name(video.Restriction))
+	}
+	switch video.Status {
+	case "", VIDEO_STATUS_RECORDED, VIDEO_STATUS_RECORDING:
+		return nil
+	}
+	return fmt.Errorf("Cannot play %q, the VOD is not available (%s)", video.Title, strings.ToLower(video.Status))
+}
+
+// Like Sort_videos_by_latest, but reruns go with the VODs by when they started
+func Sort_reruns_offline(a, b Video) int {
+	demote := func(x Video) Video {
+		if Is_rerun(x) {
+			x.Is_live = false
+			x.Duration = 0
+		}
+		return x
+	}
+	return Sort_videos_by_latest(demote(a), demote(b))
 }
 
 func Sort_videos_by_latest(a, b Video) int {
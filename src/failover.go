@@ -0,0 +1,257 @@
+package src
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Twitch can be reached through GraphQL or by scraping. Rather than every
+// channel showing an error when one of them breaks, try the next one.
+type Failover struct {
+	Backends []Backend
+	Mode     string // "auto" or the name of the only backend to use
+}
+
+type Backend struct {
+	Name     string
+	Provider Provider
+}
+
+var TWITCH = &Failover{
+	Backends: []Backend{
+		{"graphql", TwitchGraph{}},
+		{"scrape", TwitchScrape{}},
+	},
+	Mode: "auto",
+}
+
+func (self *Failover) Set_mode(mode string) error {
+	if mode == "auto" {
+		self.Mode = mode
+		return nil
+	}
+	for _, backend := range self.Backends {
+		if backend.Name == mode {
+			self.Mode = mode
+			return nil
+		}
+	}
+	names := []string{"auto"}
+	for _, backend := range self.Backends {
+		names = append(names, backend.Name)
+	}
+	return fmt.Errorf("Unknown backend %q, expected one of %v", mode, names)
+}
+
+// Backends that are not cooling down, in order of preference
+func (self *Failover) usable() []Backend {
+	if self.Mode != "auto" {
+		for _, backend := range self.Backends {
+			if backend.Name == self.Mode {
+				return []Backend{backend}
+			}
+		}
+	}
+
+	now := time.Now()
+	ret := make([]Backend, 0, len(self.Backends))
+	health_lock.Lock()
+	for _, backend := range self.Backends {
+		if x, ok := backend_health[backend.Name]; !ok || !now.Before(x.Cooldown_until) {
+			ret = append(ret, backend)
+		}
+	}
+	health_lock.Unlock()
+
+	// Better to keep trying than to show nothing at all
+	if len(ret) == 0 {
+		return self.Backends
+	}
+	return ret
+}
+
+// Run `fn` on each usable backend until one succeeds
+func (self *Failover) try(fn func(Backend) error) error {
+	var err error
+	for _, backend := range self.usable() {
+		start := time.Now()
+		err = fn(backend)
+		Record_health(backend.Name, time.Since(start), err)
+		if err == nil {
+			return nil
+		}
+		L_DEBUG.Printf("Backend %s failed: %s", backend.Name, err)
+	}
+	return err
+}
+
+func (self *Failover) Vods(channel string, cursor string, broadcast_type string) VideoPacket {
+	var packet VideoPacket
+	_ = self.try(func(backend Backend) error {
+		packet = backend.Provider.Vods(channel, cursor, broadcast_type)
+		return packet.Err
+	})
+	return packet
+}
+
+func (self *Failover) Live_status(channel string) VideoPacket {
+	var packet VideoPacket
+	_ = self.try(func(backend Backend) error {
+		packet = backend.Provider.Live_status(channel)
+		return packet.Err
+	})
+	return packet
+}
+
+func (self *Failover) Channel_info(channel string) (ChannelInfo, error) {
+	var info ChannelInfo
+	err := self.try(func(backend Backend) error {
+		x, err := backend.Provider.Channel_info(channel)
+		info = x
+		return err
+	})
+	return info, err
+}
+
+func (self *Failover) Playback_url(video Video) (string, error) {
+	var url string
+	err := self.try(func(backend Backend) error {
+		x, err := backend.Provider.Playback_url(video)
+		url = x
+		return err
+	})
+	return url, err
+}
+
+func (self *Failover) Playback_variants(video Video) ([]Variant, error) {
+	var variants []Variant
+	err := self.try(func(backend Backend) error {
+		x, err := backend.Provider.Playback_variants(video)
+		variants = x
+		return err
+	})
+	return variants, err
+}
+
+func (self *Failover) Refresh(channels []string) []VideoPacket {
+	backends := self.usable()
+	first := backends[0]
+
+	var packets []VideoPacket
+	start := time.Now()
+	if batcher, ok := first.Provider.(BatchProvider); ok {
+		packets = batcher.Refresh(channels)
+	} else {
+		packets = make([]VideoPacket, 0, len(channels) * 2)
+		for _, channel := range channels {
+			packets = append(packets, first.Provider.Vods(channel, "", ""), first.Provider.Live_status(channel))
+		}
+	}
+	// Each channel counts as its own operation so that a batch where most
+	// channels failed still counts against the backend
+	latency := time.Since(start) / time.Duration(max(len(channels), 1))
+	for i := range channels {
+		Record_health(first.Name, latency, channel_err(packets[2 * i], packets[2 * i + 1]))
+	}
+
+	// Retry the channels that failed one at a time on the other backends.
+	// Another backend will not find a channel that does not exist either.
+	for i, channel := range channels {
+		err := channel_err(packets[2 * i], packets[2 * i + 1])
+		if _, is_missing := err.(ErrMissing); err == nil || is_missing {
+			continue
+		}
+		for _, backend := range backends[1:] {
+			start := time.Now()
+			vods := backend.Provider.Vods(channel, "", "")
+			live := backend.Provider.Live_status(channel)
+			err := channel_err(vods, live)
+			Record_health(backend.Name, time.Since(start), err)
+			if err == nil {
+				packets[2 * i], packets[2 * i + 1] = vods, live
+				break
+			}
+		}
+	}
+	return packets
+}
+
+// The first error of the VOD and live packets of a channel
+func channel_err(vods VideoPacket, live VideoPacket) error {
+	if vods.Err != nil {
+		return vods.Err
+	}
+	return live.Err
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Health
+
+const FAILURES_BEFORE_COOLDOWN = 3
+var BACKEND_COOLDOWN = 5 * time.Minute
+
+type BackendHealth struct {
+	Name                 string
+	Success              uint
+	Failure              uint
+	Consecutive_failures uint
+	Total_latency        time.Duration
+	Cooldown_until       time.Time
+}
+
+func (self BackendHealth) Average_latency() time.Duration {
+	if count := self.Success + self.Failure; count > 0 {
+		return self.Total_latency / time.Duration(count)
+	}
+	return 0
+}
+
+var health_lock sync.Mutex
+var backend_health = map[string]*BackendHealth{}
+
+// A missing channel or video is an answer, not a failure of the backend
+func Record_health(name string, latency time.Duration, err error) {
+	health_lock.Lock()
+	defer health_lock.Unlock()
+
+	x, ok := backend_health[name]
+	if !ok {
+		x = &BackendHealth{Name: name}
+		backend_health[name] = x
+	}
+	x.Total_latency += latency
+
+	if _, is_missing := err.(ErrMissing); err == nil || is_missing {
+		x.Success += 1
+		x.Consecutive_failures = 0
+	} else {
+		x.Failure += 1
+		x.Consecutive_failures += 1
+		if x.Consecutive_failures >= FAILURES_BEFORE_COOLDOWN {
+			x.Cooldown_until = time.Now().Add(BACKEND_COOLDOWN)
+			L_INFO.Printf("Backend %s failed %d times in a row, not using it until %s", name, x.Consecutive_failures, x.Cooldown_until.Format(time.TimeOnly))
+		}
+	}
+}
+
+// Sorted by name
+func Health_report() []BackendHealth {
+	health_lock.Lock()
+	defer health_lock.Unlock()
+
+	ret := make([]BackendHealth, 0, len(backend_health))
+	for _, x := range backend_health {
+		ret = append(ret, *x)
+	}
+	slices.SortFunc(ret, func(a, b BackendHealth) int {
+		if a.Name < b.Name {
+			return -1
+		} else if a.Name > b.Name {
+			return 1
+		}
+		return 0
+	})
+	return ret
+}
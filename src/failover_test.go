@@ -0,0 +1,75 @@
+package src
+
+import (
+	"fmt"
+	"testing"
+
+	a "github.com/yueleshia/streamsurf/src/testify"
+)
+
+//run: go test -v
+
+type fake_provider struct {
+	name string
+	err  error
+}
+
+func (self fake_provider) Vods(channel string, cursor string, broadcast_type string) VideoPacket {
+	packet := VideoPacket{Vids: []Video{{Channel: channel}}, Err: self.err, Channel: channel}
+	packet.Stamp_backend(self.name)
+	return packet
+}
+func (self fake_provider) Live_status(channel string) VideoPacket {
+	packet := VideoPacket{Vids: []Video{{Channel: channel}}, Live: true, Err: self.err, Channel: channel}
+	packet.Stamp_backend(self.name)
+	return packet
+}
+func (self fake_provider) Channel_info(channel string) (ChannelInfo, error) { return ChannelInfo{}, self.err }
+func (self fake_provider) Playback_url(video Video) (string, error)         { return video.Url, self.err }
+func (self fake_provider) Playback_variants(video Video) ([]Variant, error) {
+	return []Variant{{Name: "720p60", Url: "https://example.com/" + self.name + ".m3u8"}}, self.err
+}
+
+func TestFailover(t *testing.T) {
+	failover := &Failover{
+		Backends: []Backend{
+			{"test-broken", fake_provider{"test-broken", fmt.Errorf("broken")}},
+			{"test-working", fake_provider{"test-working", nil}},
+		},
+		Mode: "auto",
+	}
+
+	packets := failover.Refresh([]string{"foo", "bar"})
+	a.AssertEqual(t, 4, len(packets))
+	for _, packet := range packets {
+		a.AssertEqual(t, nil, packet.Err)
+		a.AssertEqual(t, "test-working", packet.Vids[0].Backend)
+	}
+
+	// Each channel of the Refresh above counts as a failure
+	a.AssertEqual(t, 2, len(failover.usable()))
+	_ = failover.Vods("foo", "", "")
+	a.AssertEqual(t, []Backend{failover.Backends[1]}, failover.usable())
+
+	a.AssertEqual(t, nil, failover.Set_mode("test-broken"))
+	a.AssertEqual(t, "broken", failover.Vods("foo", "", "").Err.Error())
+}
+
+func TestFailoverMissing(t *testing.T) {
+	failover := &Failover{
+		Backends: []Backend{
+			{"test-missing", fake_provider{"test-missing", ErrMissing{message: "Channel foo does not exist"}}},
+			{"test-retry", fake_provider{"test-retry", nil}},
+		},
+		Mode: "auto",
+	}
+
+	// Not retried, and not a failure of the backend
+	packets := failover.Refresh([]string{"foo", "bar", "baz"})
+	for _, packet := range packets {
+		_, is_missing := packet.Err.(ErrMissing)
+		a.AssertEqual(t, true, is_missing)
+		a.AssertEqual(t, "test-missing", packet.Vids[0].Backend)
+	}
+	a.AssertEqual(t, 2, len(failover.usable()))
+}
@@ -0,0 +1,298 @@
+package src
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// A small client for https://gql.twitch.tv, every query should go through here
+// so that variables are always properly escaped
+
+const GQL_URL = "https://gql.twitch.tv/gql#origin=twilight"
+
+type GqlOperation struct {
+	Operation_name string                  `json:"operationName"`
+	Variables      any                     `json:"variables"`
+	Query          string                  `json:"query,omitempty"`
+	Extensions     *GqlOperationExtensions `json:"extensions,omitempty"`
+}
+
+type GqlOperationExtensions struct {
+	Persisted_query GqlPersistedQuery `json:"persistedQuery"`
+}
+
+type GqlPersistedQuery struct {
+	Version     int    `json:"version"`
+	Sha256_hash string `json:"sha256Hash"`
+}
+
+type GqlExtensions struct {
+	Duration_milliseconds int    `json:"durationMilliseconds"`
+	Operation_name        string `json:"operationName"`
+	Request_id            string `json:"requestID"`
+}
+
+type GqlError struct {
+	Message   string `json:"message"`
+	Path      []any  `json:"path"`
+	Locations []struct {
+		Line   int `json:"line"`
+		Column int `json:"column"`
+	} `json:"locations"`
+	Extensions json.RawMessage `json:"extensions"`
+
+	Operation_name string `json:"-"` // Filled in by us
+	Request_id     string `json:"-"` // Filled in by us
+}
+
+func (self GqlError) Error() string {
+	message := self.Message
+	if message == "PersistedQueryNotFound" {
+		// Only operations we have no full query for get here, see Gql_batch
+		message += fmt.Sprintf(", update the hash of %s in %s", self.Operation_name, PERSISTED_QUERIES_FILE)
+	}
+	if len(self.Path) == 0 {
+		return fmt.Sprintf("GQL %s (request %s): %s", self.Operation_name, self.Request_id, message)
+	}
+	path := make([]string, len(self.Path))
+	for i, x := range self.Path {
+		path[i] = fmt.Sprint(x)
+	}
+	return fmt.Sprintf("GQL %s (request %s): %s at %s", self.Operation_name, self.Request_id, message, strings.Join(path, "."))
+}
+
+// All the errors of a single operation
+type GqlErrors []GqlError
+
+func (self GqlErrors) Error() string {
+	messages := make([]string, len(self))
+	for i, x := range self {
+		messages[i] = x.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+type GqlResponse struct {
+	Data       json.RawMessage `json:"data"`
+	Errors     GqlErrors       `json:"errors"`
+	Extensions GqlExtensions   `json:"extensions"`
+}
+
+// `out` should point to a struct shaped like the `data` of the query
+func (self GqlResponse) Decode(out any) error {
+	if len(self.Errors) > 0 {
+		for i := range self.Errors {
+			self.Errors[i].Operation_name = self.Extensions.Operation_name
+			self.Errors[i].Request_id = self.Extensions.Request_id
+		}
+		return self.Errors
+	}
+	if len(self.Data) == 0 || bytes.Equal(self.Data, []byte("null")) {
+		return ErrMissing{message: fmt.Sprintf("GQL %s (request %s): no data", self.Extensions.Operation_name, self.Extensions.Request_id)}
+	}
+	return Decode_json("gql." + self.Extensions.Operation_name, self.Data, out)
+}
+
+func gql_headers() map[string]string {
+	headers := map[string]string{
+		//"Authorization": void 0,
+		"Accept": "*/*",
+		"Accept-Language": "en-US",
+		"Content-Type": "text/plain; charset=UTF-8",
+		"Client-Id": CLIENT_ID,
+	}
+	if id := Get_identity().Device_id; id != "" {
+		headers["Device-ID"] = id
+	}
+	if token := integrity_token(); token != "" {
+		headers["Client-Integrity"] = token
+	}
+	return headers
+}
+
+// The GQL batch format, a JSON array of operations answered by an array of
+// responses in the same order
+func Gql_batch(ctx context.Context, operations []GqlOperation, cache_id string) ([]GqlResponse, error) {
+	sent := persist_operations(operations, Persisted_queries())
+	responses, err := gql_send(ctx, sent, cache_id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the full text of the queries the server did not recognise the hash of
+	is_not_found := make([]bool, len(responses))
+	for i, response := range responses {
+		if response.Is_persisted_query_not_found() && operations[i].Query != "" {
+			L_DEBUG.Printf("Persisted query %s not found, sending the full query", operations[i].Operation_name)
+			is_not_found[i] = true
+			sent[i] = operations[i]
+		}
+	}
+	if err := gql_retry(ctx, sent, responses, cache_id + "-full", func(i int) bool {
+		return is_not_found[i]
+	}); err != nil {
+		return nil, err
+	}
+
+	// Only some operations require an integrity token, so we only get one when
+	// asked. Retried once, a second failure is not fixed by another token.
+	if slices.ContainsFunc(responses, GqlResponse.Is_integrity_failure) {
+		if err := Refresh_integrity(ctx); err != nil {
+			return nil, err
+		}
+		if err := gql_retry(ctx, sent, responses, cache_id + "-integrity", func(i int) bool {
+			return responses[i].Is_integrity_failure()
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return responses, nil
+}
+
+// Resend the operations for which `should_retry` is true and replace their responses
+func gql_retry(ctx context.Context, operations []GqlOperation, responses []GqlResponse, cache_id string, should_retry func(int) bool) error {
+	var retry []int
+	for i := range responses {
+		if should_retry(i) {
+			retry = append(retry, i)
+		}
+	}
+	if len(retry) == 0 {
+		return nil
+	}
+
+	resend := make([]GqlOperation, len(retry))
+	for i, idx := range retry {
+		resend[i] = operations[idx]
+	}
+	x, err := gql_send(ctx, resend, cache_id)
+	if err != nil {
+		return err
+	}
+	for i, idx := range retry {
+		responses[idx] = x[i]
+	}
+	return nil
+}
+
+func gql_send(ctx context.Context, operations []GqlOperation, cache_id string) ([]GqlResponse, error) {
+	var body []byte
+	if x, err := json.Marshal(operations); err != nil {
+		return nil, err
+	} else {
+		body = x
+	}
+
+	request, err := Request(ctx, "POST", gql_headers(), bytes.NewReader(body), GQL_URL, cache_id)
+	if err != nil {
+		return nil, err
+	}
+	defer request.Close()
+
+	var responses []GqlResponse
+	if data, err := io.ReadAll(request); err != nil {
+		return nil, err
+	} else if err := Decode_json("gql", data, &responses); err != nil {
+		return nil, err
+	}
+	if len(responses) != len(operations) {
+		return nil, fmt.Errorf("Sent %d GQL operations but got %d responses", len(operations), len(responses))
+	}
+	return responses, nil
+}
+
+// Other errors may mention integrity, but only this one is fixed by a new token
+func (self GqlResponse) Is_integrity_failure() bool {
+	for _, x := range self.Errors {
+		if x.Message == "failed integrity check" {
+			return true
+		}
+	}
+	return false
+}
+
+func (self GqlResponse) Is_persisted_query_not_found() bool {
+	for _, x := range self.Errors {
+		if x.Message == "PersistedQueryNotFound" {
+			return true
+		}
+	}
+	return false
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Persisted queries
+
+// The web client sends most operations as a sha256 of a query the server
+// already knows. Some operations are only accepted in this form.
+// Users can update these in the config directory when twitch changes them.
+const PERSISTED_QUERIES_FILE = "persisted_queries.json"
+var DEFAULT_PERSISTED_QUERIES = map[string]string{
+	"PlaybackAccessToken":           "0828119ded1c13477966434e15800ff57ddacf13ba1911c129dc2200705b0712",
+	"VideoCommentsByOffsetOrCursor": "b70a3591ff0f4e0313d126c6a1502d79a1c02baebb288227c582044aa76adf6a",
+}
+
+var persisted_once sync.Once
+var persisted_queries map[string]string
+
+func Persisted_queries() map[string]string {
+	persisted_once.Do(func() {
+		table := maps.Clone(DEFAULT_PERSISTED_QUERIES)
+		if err := Load_config_file(PERSISTED_QUERIES_FILE, &table); err != nil {
+			L_ERROR.Printf("Could not load %s, using the defaults: %s", PERSISTED_QUERIES_FILE, err)
+			table = maps.Clone(DEFAULT_PERSISTED_QUERIES)
+		}
+		persisted_queries = table
+	})
+	return persisted_queries
+}
+
+// Operations in `table` are sent by hash only. Leaves `operations` untouched
+// so that we still have the full query if the server does not know the hash.
+func persist_operations(operations []GqlOperation, table map[string]string) []GqlOperation {
+	ret := make([]GqlOperation, len(operations))
+	for i, x := range operations {
+		ret[i] = x
+		if hash, ok := table[x.Operation_name]; ok {
+			ret[i].Query = ""
+			ret[i].Extensions = &GqlOperationExtensions{GqlPersistedQuery{Version: 1, Sha256_hash: hash}}
+		}
+	}
+	return ret
+}
+
+// Decodes the `data` of the response into `out`
+func Gql(ctx context.Context, operation GqlOperation, out any, cache_id string) (GqlExtensions, error) {
+	responses, err := Gql_batch(ctx, []GqlOperation{operation}, cache_id)
+	if err != nil {
+		return GqlExtensions{}, err
+	}
+	return responses[0].Extensions, responses[0].Decode(out)
+}
+
+// For a batch with one operation per channel. Batches only share the id when
+// they ask for the same channels in the same order.
+func batch_cache_id(prefix string, channels []string) string {
+	hash := fnv.New64a()
+	for _, x := range channels {
+		_, _ = hash.Write([]byte(x))
+		_, _ = hash.Write([]byte{0}) // So that "ab", "c" is not "a", "bc"
+	}
+	return fmt.Sprintf("%s-%d-%016x", prefix, len(channels), hash.Sum64())
+}
+
+// For nullable variables
+func null_if_empty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
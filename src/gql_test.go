@@ -0,0 +1,95 @@
+package src
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	a "github.com/yueleshia/streamsurf/src/testify"
+)
+
+//run: go test -v
+
+func TestGqlVariables(t *testing.T) {
+	body, err := json.Marshal([]GqlOperation{videos_operation(`a"b\c`, "", "")})
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, true, json.Valid(body))
+
+	var decoded []struct {
+		Variables VideosVariables `json:"variables"`
+	}
+	a.AssertEqual(t, nil, json.Unmarshal(body, &decoded))
+	a.AssertEqual(t, `a"b\c`, decoded[0].Variables.Channel_owner_login)
+	a.AssertEqual(t, (*string)(nil), decoded[0].Variables.Cursor)
+}
+
+func TestGqlErrors(t *testing.T) {
+	var response GqlResponse
+	a.AssertEqual(t, nil, json.Unmarshal([]byte(`{
+		"data": null,
+		"errors": [{"message": "service timeout", "path": ["user", "videos"]}],
+		"extensions": {"durationMilliseconds": 12, "operationName": "videos", "requestID": "abc"}
+	}`), &response))
+
+	var data VideosData
+	err := response.Decode(&data)
+	errs, ok := err.(GqlErrors)
+	a.AssertEqual(t, true, ok)
+	a.AssertEqual(t, "GQL videos (request abc): service timeout at user.videos", errs[0].Error())
+	a.AssertEqual(t, 12, response.Extensions.Duration_milliseconds)
+}
+
+func TestPersistedQueries(t *testing.T) {
+	operations := []GqlOperation{
+		{Operation_name: "videos", Query: "query videos {}"},
+		{Operation_name: "PlaybackAccessToken", Query: "query PlaybackAccessToken {}"},
+	}
+	persisted := persist_operations(operations, map[string]string{"PlaybackAccessToken": "abc"})
+
+	a.AssertEqual(t, operations[0], persisted[0])
+	a.AssertEqual(t, "", persisted[1].Query)
+	a.AssertEqual(t, "abc", persisted[1].Extensions.Persisted_query.Sha256_hash)
+	// Kept for when the server does not know the hash
+	a.AssertEqual(t, "query PlaybackAccessToken {}", operations[1].Query)
+
+	response := GqlResponse{Errors: GqlErrors{{Message: "PersistedQueryNotFound"}}}
+	a.AssertEqual(t, true, response.Is_persisted_query_not_found())
+	err := response.Decode(&struct{}{})
+	a.AssertEqual(t, true, strings.Contains(err.Error(), PERSISTED_QUERIES_FILE))
+}
+
+func TestIntegrityFailure(t *testing.T) {
+	a.AssertEqual(t, true, GqlResponse{Errors: GqlErrors{{Message: "failed integrity check"}}}.Is_integrity_failure())
+	a.AssertEqual(t, false, GqlResponse{Errors: GqlErrors{{Message: "service error: integrity unavailable"}}}.Is_integrity_failure())
+
+	id := new_device_id()
+	a.AssertEqual(t, 32, len(id))
+	a.AssertEqual(t, "", strings.Trim(id, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"))
+}
+
+func TestBatchCacheId(t *testing.T) {
+	id := batch_cache_id("graph-batch", []string{"foo", "bar"})
+	a.AssertEqual(t, true, strings.HasPrefix(id, "graph-batch-2-"))
+	a.AssertEqual(t, id, batch_cache_id("graph-batch", []string{"foo", "bar"}))
+	a.AssertEqual(t, false, id == batch_cache_id("graph-batch", []string{"foo", "baz"}))
+	a.AssertEqual(t, false, id == batch_cache_id("graph-batch", []string{"fo", "obar"}))
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	// A missing file leaves the defaults and is not created
+	table := map[string]string{"a": "1"}
+	a.AssertEqual(t, nil, Load_config_file(PERSISTED_QUERIES_FILE, &table))
+	a.AssertEqual(t, map[string]string{"a": "1"}, table)
+	path, err := Config_path(PERSISTED_QUERIES_FILE)
+	a.AssertEqual(t, nil, err)
+	_, err = os.Stat(path)
+	a.AssertEqual(t, true, os.IsNotExist(err))
+
+	a.AssertEqual(t, nil, Save_config_file(PERSISTED_QUERIES_FILE, map[string]string{"b": "2"}))
+	a.AssertEqual(t, nil, Load_config_file(PERSISTED_QUERIES_FILE, &table))
+	a.AssertEqual(t, map[string]string{"a": "1", "b": "2"}, table)
+}
@@ -0,0 +1,161 @@
+package src
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Twitch rotates the public client ID every so often, so the values in
+// constants.go are only defaults. Edit them with `streamsurf identity`.
+
+const IDENTITY_FILE = "identity.json"
+
+type Identity struct {
+	Client_id            string    `json:"client_id"`
+	User_agent           string    `json:"user_agent"`
+	Device_id            string    `json:"device_id"`
+	Integrity_token      string    `json:"integrity_token"`
+	Integrity_expiration time.Time `json:"integrity_expiration"`
+}
+
+var identity_lock sync.Mutex
+var identity = Identity{}
+var is_identity_unsaved bool // A Device-ID we generated, saved once we first send it
+
+// Writes identity.json outside of the lock, requests should not wait on disk
+func Get_identity() Identity {
+	identity_lock.Lock()
+	x, is_unsaved := identity, is_identity_unsaved
+	is_identity_unsaved = false
+	identity_lock.Unlock()
+
+	if is_unsaved {
+		if err := Save_config_file(IDENTITY_FILE, x); err != nil {
+			L_ERROR.Printf("Could not save %s: %s", IDENTITY_FILE, err)
+		}
+	}
+	return x
+}
+
+// Call once on startup. Generates a Device-ID on the first run.
+func Load_identity() error {
+	identity_lock.Lock()
+	defer identity_lock.Unlock()
+
+	x := Identity{Client_id: CLIENT_ID, User_agent: USER_AGENT}
+	if err := Load_config_file(IDENTITY_FILE, &x); err != nil {
+		identity = Identity{Client_id: CLIENT_ID, User_agent: USER_AGENT}
+		return err
+	}
+	// Not saved yet, so that commands which never talk to twitch write nothing
+	if x.Device_id == "" {
+		x.Device_id = new_device_id()
+		is_identity_unsaved = true
+	}
+	identity = x
+	CLIENT_ID = x.Client_id
+	USER_AGENT = x.User_agent
+	return nil
+}
+
+// Pass a function that edits the identity, e.g. to set a new client ID
+func Update_identity(update func(*Identity)) error {
+	identity_lock.Lock()
+	defer identity_lock.Unlock()
+
+	x := identity
+	update(&x)
+	if err := Save_config_file(IDENTITY_FILE, x); err != nil {
+		return err
+	}
+	identity = x
+	is_identity_unsaved = false
+	// Only write when changed, requests in flight read these
+	if CLIENT_ID != x.Client_id {
+		CLIENT_ID = x.Client_id
+	}
+	if USER_AGENT != x.User_agent {
+		USER_AGENT = x.User_agent
+	}
+	return nil
+}
+
+func new_device_id() string {
+	const ALPHABET = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// Bytes from here up would make the start of ALPHABET more likely
+	const LIMIT = 256 - 256 % len(ALPHABET)
+	ret := make([]byte, 0, 32)
+	buffer := make([]byte, 32)
+	for len(ret) < cap(ret) {
+		_ = Must(rand.Read(buffer))
+		for _, x := range buffer {
+			if int(x) < LIMIT && len(ret) < cap(ret) {
+				ret = append(ret, ALPHABET[int(x) % len(ALPHABET)])
+			}
+		}
+	}
+	return string(ret)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Integrity
+
+// Empty if we do not have one or if it has expired
+func integrity_token() string {
+	identity_lock.Lock()
+	defer identity_lock.Unlock()
+	if time.Now().Before(identity.Integrity_expiration) {
+		return identity.Integrity_token
+	}
+	return ""
+}
+
+// Twitch only asks for this on some operations, see Gql_batch
+func Refresh_integrity(ctx context.Context) error {
+	id := Get_identity()
+	body, err := Request(ctx, "POST", map[string]string{
+		"Client-Id": CLIENT_ID,
+		"Device-ID": id.Device_id,
+	}, nil, "https://gql.twitch.tv/integrity", "integrity")
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	var response struct {
+		Token      string `json:"token"`
+		Expiration int64  `json:"expiration"` // Unix milliseconds
+		Request_id string `json:"request_id"`
+	}
+	if data, err := io.ReadAll(body); err != nil {
+		return err
+	} else if err := json.Unmarshal(data, &response); err != nil {
+		return err
+	}
+	if response.Token == "" {
+		return fmt.Errorf("Twitch did not give an integrity token (request %s)", response.Request_id)
+	}
+
+	return Update_identity(func(x *Identity) {
+		x.Integrity_token = response.Token
+		x.Integrity_expiration = time.UnixMilli(response.Expiration)
+	})
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Client ID
+
+// The web client embeds its client ID in the twitch.tv page
+func Scrape_client_id() (string, error) {
+	body, err := Request(context.TODO(), "GET", nil, nil, "https://www.twitch.tv", "scrape-client-id")
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	return read_twitch_client_id(body)
+}
@@ -0,0 +1,45 @@
+package src
+
+import (
+	"time"
+)
+
+// Channels that do not keep VODs have nothing to show when offline, so we
+// remember when we last saw them live. GraphQL knows the start of the last
+// broadcast too, but not when it ended.
+
+const LAST_LIVE_FILE = "last_live.json"
+
+// Seeing a channel live on every refresh should not mean a write every refresh
+const LAST_LIVE_SAVE_INTERVAL = 10 * time.Minute
+
+type LastBroadcast struct {
+	Time  time.Time `json:"time"`
+	Title string    `json:"title"`
+}
+
+func (self LastBroadcast) Is_zero() bool {
+	return self.Time.IsZero()
+}
+
+// The later of the two
+func Latest_broadcast(a, b LastBroadcast) LastBroadcast {
+	if b.Time.After(a.Time) {
+		return b
+	}
+	return a
+}
+
+// By login
+func Load_last_live() map[string]LastBroadcast {
+	table := map[string]LastBroadcast{}
+	if err := Load_config_file(LAST_LIVE_FILE, &table); err != nil {
+		L_ERROR.Printf("Could not load %s: %s", LAST_LIVE_FILE, err)
+		return map[string]LastBroadcast{}
+	}
+	return table
+}
+
+func Save_last_live(table map[string]LastBroadcast) error {
+	return Save_config_file(LAST_LIVE_FILE, table)
+}
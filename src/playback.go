@@ -0,0 +1,376 @@
+package src
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/url"
+	"os/exec"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// What streamlink does to get a playlist, without needing streamlink
+// 1. Get a playback access token for the stream or VOD from GQL
+// 2. Ask usher for the master playlist with that token
+// 3. Pick one of the variants in the master playlist and give it to a player
+
+////////////////////////////////////////////////////////////////////////////////
+// Access token
+
+type PlaybackToken struct {
+	Value     string `json:"value"`
+	Signature string `json:"signature"`
+}
+
+// Sent by hash, see DEFAULT_PERSISTED_QUERIES. The full query is only sent
+// when twitch no longer knows the hash.
+// @VOLATILE: This is what the web player asked for before it only sent the hash
+var PLAYBACK_TOKEN_GRAPHQL_QUERY = strings.ReplaceAll(`query PlaybackAccessToken($login: String!, $isLive: Boolean!, $vodID: ID!, $isVod: Boolean!, $playerType: String!, $platform: String!) {
+    streamPlaybackAccessToken(channelName: $login, params: {platform: $platform, playerBackend: "mediaplayer", playerType: $playerType}) @include(if: $isLive) {
+        value
+        signature
+    }
+    videoPlaybackAccessToken(id: $vodID, params: {platform: $platform, playerBackend: "mediaplayer", playerType: $playerType}) @include(if: $isVod) {
+        value
+        signature
+    }
+}`, "\n", "")
+
+type PlaybackTokenVariables struct {
+	Is_live     bool   `json:"isLive"`
+	Login       string `json:"login"`
+	Is_vod      bool   `json:"isVod"`
+	Vod_id      string `json:"vodID"`
+	Player_type string `json:"playerType"`
+	Platform    string `json:"platform"`
+}
+
+// e.g. "https://www.twitch.tv/videos/123" is "123"
+func Vod_id(video Video) (string, error) {
+	parsed, err := url.Parse(video.Url)
+	if err != nil {
+		return "", err
+	}
+	dir, id := path.Split(strings.TrimSuffix(parsed.Path, "/"))
+	if dir != "/videos/" || id == "" {
+		return "", fmt.Errorf("Not the URL of a VOD: %q", video.Url)
+	}
+	return id, nil
+}
+
+func Graph_playback_token(video Video) (PlaybackToken, error) {
+	variables := PlaybackTokenVariables{Player_type: "site", Platform: "web"}
+	cache_id := ""
+	if video.Is_live {
+		variables.Is_live = true
+		variables.Login = video.Channel
+		cache_id = "graph-token-" + video.Channel
+	} else if id, err := Vod_id(video); err != nil {
+		return PlaybackToken{}, err
+	} else {
+		variables.Is_vod = true
+		variables.Vod_id = id
+		cache_id = "graph-token-vod-" + id
+	}
+
+	var data struct {
+		Stream *PlaybackToken `json:"streamPlaybackAccessToken"`
+		Video  *PlaybackToken `json:"videoPlaybackAccessToken"`
+	}
+	if _, err := Gql(context.TODO(), GqlOperation{
+		Operation_name: "PlaybackAccessToken",
+		Variables: variables,
+		Query: PLAYBACK_TOKEN_GRAPHQL_QUERY,
+	}, &data, cache_id); err != nil {
+		return PlaybackToken{}, err
+	}
+
+	token := data.Video
+	if video.Is_live {
+		token = data.Stream
+	}
+	if token == nil || token.Value == "" {
+		if video.Is_live {
+			return PlaybackToken{}, ErrMissing{message: video.Channel + " is not live"}
+		}
+		return PlaybackToken{}, ErrMissing{message: "No playback token for " + video.Url}
+	}
+	return *token, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Usher
+
+func Usher_url(video Video, token PlaybackToken) (string, error) {
+	query := url.Values{}
+	query.Set("sig", token.Signature)
+	query.Set("token", token.Value)
+	query.Set("allow_source", "true")
+	query.Set("allow_audio_only", "true")
+	query.Set("playlist_include_framerate", "true")
+	query.Set("player", "twitchweb")
+	query.Set("p", strconv.Itoa(rand.IntN(1_000_000)))
+
+	if video.Is_live {
+		query.Set("fast_bread", "true")
+		return "https://usher.ttvnw.net/api/channel/hls/" + url.PathEscape(video.Channel) + ".m3u8?" + query.Encode(), nil
+	}
+	id, err := Vod_id(video)
+	if err != nil {
+		return "", err
+	}
+	return "https://usher.ttvnw.net/vod/" + url.PathEscape(id) + ".m3u8?" + query.Encode(), nil
+}
+
+// One entry of the master playlist
+type Variant struct {
+	Name          string // e.g. "1080p60 (source)", "720p30", "audio_only"
+	Group_id      string // e.g. "chunked", "720p30", "audio_only"
+	Resolution    string // e.g. "1920x1080", "" for audio only
+	Bandwidth     int    // Bits per second
+	Codecs        string
+	Frame_rate    float64
+	Is_audio_only bool
+	Url           string // The media playlist to give a player
+}
+
+// Reads the #EXT-X-MEDIA and #EXT-X-STREAM-INF tags of an HLS master playlist
+func Parse_master_playlist(input io.Reader) ([]Variant, error) {
+	names := map[string]string{} // GROUP-ID to NAME
+	var variants []Variant
+	var pending *Variant
+
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 64 * 1024), 1024 * 1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if attrs, ok := strings.CutPrefix(line, "#EXT-X-MEDIA:"); ok {
+			x := parse_m3u8_attributes(attrs)
+			names[x["GROUP-ID"]] = x["NAME"]
+		} else if attrs, ok := strings.CutPrefix(line, "#EXT-X-STREAM-INF:"); ok {
+			x := parse_m3u8_attributes(attrs)
+			variant := Variant{
+				Group_id:   x["VIDEO"],
+				Resolution: x["RESOLUTION"],
+				Codecs:     x["CODECS"],
+			}
+			variant.Bandwidth, _ = strconv.Atoi(x["BANDWIDTH"])
+			variant.Frame_rate, _ = strconv.ParseFloat(x["FRAME-RATE"], 64)
+			variant.Is_audio_only = variant.Group_id == "audio_only" || (variant.Resolution == "" && !strings.Contains(variant.Codecs, "avc1") && !strings.Contains(variant.Codecs, "hvc1"))
+			pending = &variant
+		} else if !strings.HasPrefix(line, "#") && pending != nil {
+			pending.Url = line
+			pending.Name = names[pending.Group_id]
+			if pending.Name == "" {
+				pending.Name = pending.Group_id
+			}
+			variants = append(variants, *pending)
+			pending = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return variants, err
+	}
+	if len(variants) == 0 {
+		return variants, ErrMissing{message: "No variants in the master playlist"}
+	}
+	return variants, nil
+}
+
+// e.g. `BANDWIDTH=123,CODECS="avc1.4D401F,mp4a.40.2"`, values may be quoted
+func parse_m3u8_attributes(attrs string) map[string]string {
+	ret := map[string]string{}
+	for len(attrs) > 0 {
+		key, rest, ok := strings.Cut(attrs, "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			close := strings.IndexByte(rest[1:], '"')
+			if close < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:close + 1], rest[close + 2:]
+			}
+			rest = strings.TrimPrefix(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		ret[strings.TrimSpace(key)] = value
+		attrs = rest
+	}
+	return ret
+}
+
+func Resolve_variants(video Video) ([]Variant, error) {
+	token, err := Graph_playback_token(video)
+	if err != nil {
+		return nil, err
+	}
+	master, err := Usher_url(video, token)
+	if err != nil {
+		return nil, err
+	}
+	cache_id := "usher-" + video.Channel
+	if !video.Is_live {
+		cache_id = "usher-" + video.Url
+	}
+	body, err := Request(context.TODO(), "GET", nil, nil, master, cache_id)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return Parse_master_playlist(body)
+}
+
+// `quality` is "best", "worst", "audio_only", or the start of a variant name,
+// e.g. "720p" matches "720p60"
+func Pick_variant(variants []Variant, quality string) (Variant, error) {
+	var videos []Variant
+	for _, x := range variants {
+		if x.Is_audio_only {
+			if quality == "audio_only" || quality == "audio" {
+				return x, nil
+			}
+		} else {
+			videos = append(videos, x)
+		}
+	}
+	if len(videos) == 0 {
+		return Variant{}, ErrMissing{message: "No variant for quality " + quality}
+	}
+	slices.SortStableFunc(videos, func(a, b Variant) int { return b.Bandwidth - a.Bandwidth })
+
+	switch quality {
+	case "", "best", "source":
+		return videos[0], nil
+	case "worst":
+		return videos[len(videos) - 1], nil
+	}
+	for _, x := range videos {
+		if strings.HasPrefix(x.Name, quality) || x.Group_id == quality {
+			return x, nil
+		}
+	}
+	return Variant{}, ErrMissing{message: "No variant for quality " + quality}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Player
+
+const PLAYER_FILE = "player.json"
+
+const (
+	PLAYER_NATIVE     = "native"     // Resolve the playlist ourselves and pass it to Command
+	PLAYER_STREAMLINK = "streamlink" // Let streamlink resolve and play it
+)
+
+type PlayerConfig struct {
+	Backend    string   `json:"backend"`
+	Command    []string `json:"command"`    // The player and its arguments, the URL is appended
+	Start_flag string   `json:"start_flag"` // Prefixed to the start offset, e.g. "--start="
+	Quality    string   `json:"quality"`    // See Pick_variant
+	Skip_muted bool     `json:"skip_muted"` // Start after the muted audio if the start offset is in it
+}
+
+var DEFAULT_PLAYER = PlayerConfig{
+	Backend:    PLAYER_NATIVE,
+	Command:    []string{"mpv"},
+	Start_flag: "--start=",
+	Quality:    "best",
+	Skip_muted: true,
+}
+
+func Load_player() PlayerConfig {
+	config := DEFAULT_PLAYER
+	if err := Load_config_file(PLAYER_FILE, &config); err != nil {
+		L_ERROR.Printf("Could not load %s, using the defaults: %s", PLAYER_FILE, err)
+		return DEFAULT_PLAYER
+	}
+	return config
+}
+
+// Moves `offset` past muted audio when the config asks for it. Unparsable
+// offsets are left for the player to complain about.
+func Start_offset(config PlayerConfig, video Video, offset string) string {
+	if !config.Skip_muted || offset == "" {
+		return offset
+	}
+	position, err := Parse_offset(offset)
+	if err != nil {
+		return offset
+	}
+	if skipped := video.Skip_muted(position); skipped != position {
+		return Format_offset(skipped)
+	}
+	return offset
+}
+
+// Scraped VODs do not list their muted ranges, but the media playlist does.
+// Only the native backend has the playlist at hand.
+func skip_muted_in_playlist(config PlayerConfig, video Video, variant Variant, offset string) string {
+	if !config.Skip_muted || offset == "" || video.Is_live || video.Muted != nil || video.Backend == "graphql" {
+		return offset
+	}
+	segments, err := Fetch_media_playlist(variant)
+	if err != nil {
+		L_DEBUG.Printf("Could not look for muted segments in %s: %s", variant.Url, err)
+		return offset
+	}
+	video.Muted = Muted_ranges(segments)
+	return Start_offset(config, video, offset)
+}
+
+// `provider` is the one of the channel of `video`, see ChannelEntry.Get_provider
+// `offset` is e.g. "1:00:00", "" to start at the beginning (or live)
+func Player_command(ctx context.Context, config PlayerConfig, provider Provider, video Video, offset string) (*exec.Cmd, error) {
+	if err := Check_playable(video); err != nil {
+		return nil, err
+	}
+	switch config.Backend {
+	case PLAYER_STREAMLINK:
+		page_url, err := provider.Playback_url(video)
+		if err != nil {
+			return nil, err
+		}
+		args := []string{}
+		if offset != "" {
+			args = append(args, "--hls-start-offset", offset)
+		}
+		args = append(args, page_url)
+		if config.Quality != "" {
+			args = append(args, config.Quality)
+		}
+		return exec.CommandContext(ctx, "streamlink", args...), nil
+
+	case PLAYER_NATIVE, "":
+		if len(config.Command) == 0 {
+			return nil, fmt.Errorf("No player command in %s", PLAYER_FILE)
+		}
+		variants, err := provider.Playback_variants(video)
+		if err != nil {
+			return nil, err
+		}
+		variant, err := Pick_variant(variants, config.Quality)
+		if err != nil {
+			return nil, err
+		}
+		offset = skip_muted_in_playlist(config, video, variant, offset)
+		args := slices.Clone(config.Command[1:])
+		if offset != "" && config.Start_flag != "" {
+			args = append(args, config.Start_flag + offset)
+		}
+		args = append(args, variant.Url)
+		return exec.CommandContext(ctx, config.Command[0], args...), nil
+	}
+	return nil, fmt.Errorf("Unknown player backend %q in %s, expected %s or %s", config.Backend, PLAYER_FILE, PLAYER_NATIVE, PLAYER_STREAMLINK)
+}
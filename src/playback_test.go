@@ -0,0 +1,79 @@
+package src
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	a "github.com/yueleshia/streamsurf/src/testify"
+)
+
+//run: go test -v
+
+const MASTER_PLAYLIST = `#EXTM3U
+#EXT-X-TWITCH-INFO:NODE="video-edge",MANIFEST-NODE-TYPE="weaver_cluster"
+#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="chunked",NAME="1080p60 (source)",AUTOSELECT=YES,DEFAULT=YES
+#EXT-X-STREAM-INF:BANDWIDTH=8534030,RESOLUTION=1920x1080,CODECS="avc1.64002A,mp4a.40.2",VIDEO="chunked",FRAME-RATE=60.000
+https://example.com/chunked/index.m3u8
+#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="720p30",NAME="720p",AUTOSELECT=YES,DEFAULT=YES
+#EXT-X-STREAM-INF:BANDWIDTH=2373000,RESOLUTION=1280x720,CODECS="avc1.4D401F,mp4a.40.2",VIDEO="720p30",FRAME-RATE=30.000
+https://example.com/720p30/index.m3u8
+#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="audio_only",NAME="audio_only",AUTOSELECT=NO,DEFAULT=NO
+#EXT-X-STREAM-INF:BANDWIDTH=160000,CODECS="mp4a.40.2",VIDEO="audio_only"
+https://example.com/audio_only/index.m3u8
+`
+
+func TestMasterPlaylist(t *testing.T) {
+	variants, err := Parse_master_playlist(strings.NewReader(MASTER_PLAYLIST))
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, 3, len(variants))
+	a.AssertEqual(t, Variant{
+		Name: "1080p60 (source)", Group_id: "chunked", Resolution: "1920x1080", Bandwidth: 8534030,
+		Codecs: "avc1.64002A,mp4a.40.2", Frame_rate: 60, Url: "https://example.com/chunked/index.m3u8",
+	}, variants[0])
+	a.AssertEqual(t, true, variants[2].Is_audio_only)
+
+	pick := func(quality string) string {
+		x, err := Pick_variant(variants, quality)
+		if err != nil {
+			return err.Error()
+		}
+		return x.Group_id
+	}
+	a.AssertEqual(t, "chunked", pick("best"))
+	a.AssertEqual(t, "720p30", pick("worst"))
+	a.AssertEqual(t, "720p30", pick("720p"))
+	a.AssertEqual(t, "audio_only", pick("audio_only"))
+	a.AssertEqual(t, "No variant for quality 480p", pick("480p"))
+
+	_, err = Parse_master_playlist(strings.NewReader("#EXTM3U\n"))
+	_, is_missing := err.(ErrMissing)
+	a.AssertEqual(t, true, is_missing)
+}
+
+func TestUsherUrl(t *testing.T) {
+	id, err := Vod_id(Video{Url: "https://www.twitch.tv/videos/123"})
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, "123", id)
+	_, err = Vod_id(Video{Url: "https://www.twitch.tv/foo"})
+	a.AssertEqual(t, true, err != nil)
+
+	token := PlaybackToken{Value: `{"a":1}`, Signature: "abc"}
+	live, _ := Usher_url(Video{Channel: "foo", Is_live: true}, token)
+	a.AssertEqual(t, true, strings.HasPrefix(live, "https://usher.ttvnw.net/api/channel/hls/foo.m3u8?"))
+	a.AssertEqual(t, true, strings.Contains(live, "sig=abc"))
+	vod, _ := Usher_url(Video{Url: "https://www.twitch.tv/videos/123"}, token)
+	a.AssertEqual(t, true, strings.HasPrefix(vod, "https://usher.ttvnw.net/vod/123.m3u8?"))
+
+	config := PlayerConfig{Backend: PLAYER_STREAMLINK, Quality: "best"}
+	provider := fake_provider{"test", nil}
+	cmd, err := Player_command(context.Background(), config, provider, Video{Url: "https://www.twitch.tv/videos/123"}, "1:00:00")
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, []string{"streamlink", "--hls-start-offset", "1:00:00", "https://www.twitch.tv/videos/123", "best"}, cmd.Args)
+
+	// The native player takes the playlist from the provider rather than Twitch
+	config = PlayerConfig{Backend: PLAYER_NATIVE, Command: []string{"mpv"}, Start_flag: "--start=", Quality: "720p60"}
+	cmd, err = Player_command(context.Background(), config, provider, Video{Url: "https://www.twitch.tv/videos/123", Backend: "graphql"}, "1:00:00")
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, []string{"mpv", "--start=1:00:00", "https://example.com/test.m3u8"}, cmd.Args)
+}
@@ -0,0 +1,183 @@
+package src
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Anything we can follow channels through. Adding a platform should only need
+// a new Provider and a Register_provider call in init().
+type Provider interface {
+	// Pass the VideoPacket.Next of the previous page as `cursor` to get the
+	// page after it. An empty `cursor` requests the first page.
+	// `broadcast_type` is one of the BROADCAST_* constants, "" for every type.
+	Vods(channel string, cursor string, broadcast_type string) VideoPacket
+	// Always a packet of a single video, which has Is_live false when offline
+	Live_status(channel string) VideoPacket
+	Channel_info(channel string) (ChannelInfo, error)
+	// The URL streamlink expects
+	Playback_url(video Video) (string, error)
+	// The renditions the native player and clips pick from, see Pick_variant
+	Playback_variants(video Video) ([]Variant, error)
+}
+
+// For providers that can get the VODs and live status of many channels in one
+// request. Returns the VOD packet then the live packet of each channel, in the
+// order of `channels`.
+type BatchProvider interface {
+	Provider
+	Refresh(channels []string) []VideoPacket
+}
+
+type ChannelInfo struct {
+	Id           string
+	Login        string
+	Display_name string
+	Description  string
+	Avatar_URL   string
+
+	// For the About screen, not every provider knows these
+	Followers    int
+	Is_partner   bool
+	Is_affiliate bool
+	Team         string // Display name of the primary team, "" if none
+	Socials      []SocialLink
+	Panels       []Panel
+}
+
+type SocialLink struct {
+	Name  string // e.g. "youtube"
+	Title string
+	Url   string
+}
+
+// The boxes below the player on the channel's about page
+type Panel struct {
+	Title       string
+	Description string // Markdown
+	Link_URL    string
+	Image_URL   string
+}
+
+const DEFAULT_PROVIDER = "twitch"
+
+var providers = map[string]Provider{}
+
+func init() {
+	Register_provider("twitch", TWITCH)
+	Register_provider("twitch-graphql", TwitchGraph{})
+	Register_provider("twitch-scrape", TwitchScrape{})
+}
+
+func Register_provider(name string, provider Provider) {
+	providers[name] = provider
+}
+
+func Get_provider(name string) (Provider, error) {
+	if provider, ok := providers[name]; ok {
+		return provider, nil
+	}
+	return nil, fmt.Errorf("Unknown provider %q", name)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Channel list
+
+// A line in the channel list, e.g. "twitch:foo", or "foo" for DEFAULT_PROVIDER
+// Options follow the channel separated by spaces:
+//   hide=upload,highlight  Do not list these broadcast types unless asked to
+//   top=5                  For categories, how many live streams to follow
+//
+// "category:<slug>" is not a provider, but follows the top live streams of a
+// category instead, e.g. "category:just-chatting"
+type ChannelEntry struct {
+	Provider   string
+	Login      string
+	Hide_types []string
+	Top        int
+	Id         string // Twitch user ID, which survives renames, "" until we write it
+	Source     string // e.g. "user:foo" if `streamsurf import` added it, "" if added by hand
+}
+
+const CATEGORY_PREFIX = "category"
+const DEFAULT_CATEGORY_TOP = 5
+
+func Parse_channel_entry(line string) ChannelEntry {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ChannelEntry{Provider: DEFAULT_PROVIDER}
+	}
+
+	var entry ChannelEntry
+	if provider, login, ok := strings.Cut(fields[0], ":"); ok {
+		entry = ChannelEntry{Provider: provider, Login: login}
+	} else {
+		entry = ChannelEntry{Provider: DEFAULT_PROVIDER, Login: fields[0]}
+	}
+
+	for _, option := range fields[1:] {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "hide":
+			for _, x := range strings.Split(value, ",") {
+				if ty, err := Parse_broadcast_type(x); err != nil || ty == "" {
+					L_ERROR.Printf("Ignoring hide=%s for %s: not a broadcast type", x, entry.Login)
+				} else {
+					entry.Hide_types = append(entry.Hide_types, ty)
+				}
+			}
+		case "top":
+			if n, err := strconv.Atoi(value); err != nil || n < 0 {
+				L_ERROR.Printf("Ignoring top=%s for %s: not a count", value, entry.Login)
+			} else {
+				entry.Top = n
+			}
+		case "id":
+			entry.Id = value
+		case "from":
+			entry.Source = value
+		default:
+			L_ERROR.Printf("Unknown option %q for %s", option, entry.Login)
+		}
+	}
+	if entry.Is_category() && entry.Top == 0 {
+		entry.Top = DEFAULT_CATEGORY_TOP
+	}
+	return entry
+}
+
+func (self ChannelEntry) Is_category() bool {
+	return self.Provider == CATEGORY_PREFIX
+}
+
+// Round-trips through Parse_channel_entry
+func (self ChannelEntry) String() string {
+	line := self.Provider + ":" + self.Login
+	if len(self.Hide_types) > 0 {
+		names := make([]string, len(self.Hide_types))
+		for i, x := range self.Hide_types {
+			names[i] = Broadcast_type_name(x)
+		}
+		line += " hide=" + strings.Join(names, ",")
+	}
+	if self.Is_category() && self.Top != DEFAULT_CATEGORY_TOP {
+		line += fmt.Sprintf(" top=%d", self.Top)
+	}
+	if self.Id != "" {
+		line += " id=" + self.Id
+	}
+	if self.Source != "" {
+		line += " from=" + self.Source
+	}
+	return line
+}
+
+func (self ChannelEntry) Is_hidden(video Video) bool {
+	return slices.Contains(self.Hide_types, video.Broadcast_type)
+}
+
+func (self ChannelEntry) Get_provider() (Provider, error) {
+	return Get_provider(self.Provider)
+}
@@ -0,0 +1,35 @@
+package src
+
+import (
+	"testing"
+
+	a "github.com/yueleshia/streamsurf/src/testify"
+)
+
+//run: go test -v
+
+func TestChannelEntry(t *testing.T) {
+	entry := Parse_channel_entry("  twitch-scrape:foo hide=uploads,highlight ")
+	a.AssertEqual(t, "twitch-scrape", entry.Provider)
+	a.AssertEqual(t, "foo", entry.Login)
+	a.AssertEqual(t, []string{BROADCAST_UPLOAD, BROADCAST_HIGHLIGHT}, entry.Hide_types)
+	a.AssertEqual(t, "twitch-scrape:foo hide=upload,highlight", entry.String())
+	a.AssertEqual(t, entry, Parse_channel_entry(entry.String()))
+
+	a.AssertEqual(t, true, entry.Is_hidden(Video{Broadcast_type: BROADCAST_UPLOAD}))
+	a.AssertEqual(t, false, entry.Is_hidden(Video{Broadcast_type: BROADCAST_ARCHIVE}))
+
+	a.AssertEqual(t, "twitch:bar", Parse_channel_entry("bar").String())
+}
+
+func TestCategoryEntry(t *testing.T) {
+	entry := Parse_channel_entry("category:just-chatting")
+	a.AssertEqual(t, true, entry.Is_category())
+	a.AssertEqual(t, "just-chatting", entry.Login)
+	a.AssertEqual(t, DEFAULT_CATEGORY_TOP, entry.Top)
+	a.AssertEqual(t, "category:just-chatting", entry.String())
+
+	entry = Parse_channel_entry("category:chess top=3")
+	a.AssertEqual(t, 3, entry.Top)
+	a.AssertEqual(t, entry, Parse_channel_entry(entry.String()))
+}
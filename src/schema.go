@@ -0,0 +1,158 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Twitch adds fields all the time, which should not break a refresh. Instead of
+// DisallowUnknownFields() we note which fields differ from our structs, and
+// `streamsurf doctor --schema` prints them.
+
+// Decodes `data` into `out`, `name` is what the drift report files it under
+func Decode_json(name string, data []byte, out any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if STRICT_DECODE {
+		dec.DisallowUnknownFields()
+		return dec.Decode(out)
+	}
+	if err := dec.Decode(out); err != nil {
+		return err
+	}
+
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil // Already decoded fine, so this should not happen
+	}
+	drift := Drift{Name: name}
+	diff_schema("", reflect.TypeOf(out), raw, &drift)
+	record_drift(drift)
+	return nil
+}
+
+type Drift struct {
+	Name        string
+	Appeared    []string // In the JSON but not in our struct
+	Disappeared []string // In our struct but not in the JSON
+}
+
+var raw_message_type = reflect.TypeOf(json.RawMessage{})
+
+func diff_schema(path string, ty reflect.Type, raw any, drift *Drift) {
+	for ty.Kind() == reflect.Pointer {
+		ty = ty.Elem()
+	}
+	if raw == nil || ty == raw_message_type {
+		return
+	}
+
+	switch ty.Kind() {
+	case reflect.Struct:
+		object, ok := raw.(map[string]any)
+		if !ok {
+			return
+		}
+		known := map[string]bool{}
+		for _, field := range json_fields(ty) {
+			key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if key == "-" || !field.IsExported() {
+				continue
+			} else if key == "" {
+				key = field.Name
+			}
+			known[key] = true
+
+			if value, ok := object[key]; ok {
+				diff_schema(path + "." + key, field.Type, value, drift)
+			} else {
+				drift.Disappeared = append(drift.Disappeared, path + "." + key)
+			}
+		}
+		for key := range object {
+			if !known[key] {
+				drift.Appeared = append(drift.Appeared, path + "." + key)
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		if list, ok := raw.([]any); ok {
+			for _, x := range list {
+				diff_schema(path + "[]", ty.Elem(), x, drift)
+			}
+		}
+
+	case reflect.Map:
+		if object, ok := raw.(map[string]any); ok {
+			for _, x := range object {
+				diff_schema(path + ".*", ty.Elem(), x, drift)
+			}
+		}
+	}
+}
+
+// Fields of embedded structs count as fields of `ty`, as in encoding/json
+func json_fields(ty reflect.Type) []reflect.StructField {
+	var ret []reflect.StructField
+	for i := 0; i < ty.NumField(); i += 1 {
+		field := ty.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			ret = append(ret, json_fields(field.Type)...)
+		} else {
+			ret = append(ret, field)
+		}
+	}
+	return ret
+}
+
+var drift_lock sync.Mutex
+var drift_report = map[string]map[string]bool{} // name -> "+path" or "-path"
+
+func record_drift(drift Drift) {
+	if len(drift.Appeared) == 0 && len(drift.Disappeared) == 0 {
+		return
+	}
+	drift_lock.Lock()
+	defer drift_lock.Unlock()
+
+	paths, ok := drift_report[drift.Name]
+	if !ok {
+		paths = map[string]bool{}
+		drift_report[drift.Name] = paths
+	}
+	for _, x := range drift.Appeared {
+		if !paths["+" + x] {
+			L_DEBUG.Printf("Schema drift in %s, new field %s", drift.Name, x)
+		}
+		paths["+" + x] = true
+	}
+	for _, x := range drift.Disappeared {
+		paths["-" + x] = true
+	}
+}
+
+// Sorted by name then path, with duplicates (e.g. from array elements) removed
+func Drift_report() []Drift {
+	drift_lock.Lock()
+	defer drift_lock.Unlock()
+
+	ret := make([]Drift, 0, len(drift_report))
+	for name, paths := range drift_report {
+		drift := Drift{Name: name}
+		for x := range paths {
+			if path, ok := strings.CutPrefix(x, "+"); ok {
+				drift.Appeared = append(drift.Appeared, path)
+			} else {
+				drift.Disappeared = append(drift.Disappeared, x[1:])
+			}
+		}
+		slices.Sort(drift.Appeared)
+		slices.Sort(drift.Disappeared)
+		ret = append(ret, drift)
+	}
+	slices.SortFunc(ret, func(a, b Drift) int { return strings.Compare(a.Name, b.Name) })
+	return ret
+}
@@ -0,0 +1,40 @@
+package src
+
+import (
+	"testing"
+
+	a "github.com/yueleshia/streamsurf/src/testify"
+)
+
+//run: go test -v
+
+func TestSchemaDrift(t *testing.T) {
+	type Node struct {
+		Id    string `json:"id"`
+		Title string `json:"title"`
+	}
+	type Data struct {
+		User *struct {
+			Login string `json:"login"`
+			Nodes []Node `json:"nodes"`
+		} `json:"user"`
+	}
+
+	var data Data
+	err := Decode_json("test.drift", []byte(`{"user": {
+		"login": "foo",
+		"isPartner": true,
+		"nodes": [{"id": "1", "title": "a", "viewCount": 3}, {"id": "2", "viewCount": 4}]
+	}}`), &data)
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, "foo", data.User.Login)
+
+	for _, drift := range Drift_report() {
+		if drift.Name == "test.drift" {
+			a.AssertEqual(t, []string{".user.isPartner", ".user.nodes[].viewCount"}, drift.Appeared)
+			a.AssertEqual(t, []string{".user.nodes[].title"}, drift.Disappeared)
+			return
+		}
+	}
+	t.Errorf("No drift recorded")
+}
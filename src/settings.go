@@ -0,0 +1,58 @@
+package src
+
+import (
+	"time"
+)
+
+// Knobs of the follow screen that are not about any one channel
+const SETTINGS_FILE = "settings.json"
+
+type Settings struct {
+	Raid_minutes int    `json:"raid_minutes"` // How long "→ raided <channel>" stays on a row, 0 to never show it
+	Reruns       string `json:"reruns"`       // One of RERUNS_*
+	Batch_size   int    `json:"batch_size"`   // Sets BATCH_SIZE, at least 1
+}
+
+// Where the follow screen puts channels that are live with a rerun
+const (
+	RERUNS_LIVE    = "live"    // With the live channels
+	RERUNS_OFFLINE = "offline" // With the offline channels, by when the rerun started
+	RERUNS_HIDE    = "hide"    // Show the latest VOD as if the channel was offline
+)
+
+var DEFAULT_SETTINGS = Settings{
+	Raid_minutes: 30,
+	Reruns:       RERUNS_LIVE,
+	Batch_size:   BATCH_SIZE,
+}
+
+func Load_settings() Settings {
+	settings := DEFAULT_SETTINGS
+	if err := Load_config_file(SETTINGS_FILE, &settings); err != nil {
+		L_ERROR.Printf("Could not load %s, using the defaults: %s", SETTINGS_FILE, err)
+		return DEFAULT_SETTINGS
+	}
+	switch settings.Reruns {
+	case RERUNS_LIVE, RERUNS_OFFLINE, RERUNS_HIDE:
+	default:
+		L_ERROR.Printf("Unknown \"reruns\": %q in %s, expected %s, %s or %s", settings.Reruns, SETTINGS_FILE, RERUNS_LIVE, RERUNS_OFFLINE, RERUNS_HIDE)
+		settings.Reruns = RERUNS_LIVE
+	}
+	if settings.Batch_size < 1 {
+		L_ERROR.Printf("\"batch_size\": %d in %s has to be at least 1", settings.Batch_size, SETTINGS_FILE)
+		settings.Batch_size = DEFAULT_SETTINGS.Batch_size
+	}
+	BATCH_SIZE = settings.Batch_size
+	return settings
+}
+
+func (self Settings) Sort_follow() func(a, b Video) int {
+	if self.Reruns == RERUNS_OFFLINE {
+		return Sort_reruns_offline
+	}
+	return Sort_videos_by_latest
+}
+
+func (self Settings) Raid_duration() time.Duration {
+	return time.Duration(self.Raid_minutes) * time.Minute
+}
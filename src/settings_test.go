@@ -0,0 +1,26 @@
+package src
+
+import (
+	"testing"
+
+	a "github.com/yueleshia/streamsurf/src/testify"
+)
+
+//run: go test -v
+
+func TestSettingsBatchSize(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	defer func(x int) { BATCH_SIZE = x }(BATCH_SIZE)
+
+	settings := DEFAULT_SETTINGS
+	settings.Batch_size = 5
+	a.AssertEqual(t, nil, Save_config_file(SETTINGS_FILE, settings))
+	a.AssertEqual(t, 5, Load_settings().Batch_size)
+	a.AssertEqual(t, 5, BATCH_SIZE)
+
+	settings.Batch_size = 0
+	a.AssertEqual(t, nil, Save_config_file(SETTINGS_FILE, settings))
+	a.AssertEqual(t, DEFAULT_SETTINGS.Batch_size, Load_settings().Batch_size)
+	a.AssertEqual(t, DEFAULT_SETTINGS.Batch_size, BATCH_SIZE)
+}
@@ -54,6 +54,7 @@ const Restore_cursor_position = "\x1B[u";
 const Clear = "\x1B[2J";
 
 const Reset_attributes = "\x1B[0m";
+const Dim = "\x1B[2m";
 
 const Overwrite_mode = "\x1B[4l";
 
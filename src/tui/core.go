@@ -1,8 +1,11 @@
 package tui
 
 import (
+	"context"
 	"io"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,8 +17,18 @@ import (
 const (
 	ScreenFollow int = iota
 	ScreenChannel
+	ScreenCategory
+	ScreenSearch
+	ScreenAbout
+	ScreenSchedule
 )
 
+type SearchPacket struct {
+	Query   string
+	Results []src.SearchResult
+	Err     error
+}
+
 type FollowPair struct {
 	Live   src.Video
 	Latest src.Video
@@ -24,9 +37,13 @@ type FollowPair struct {
 type UIState struct {
 	Height, Width int
 	Screen int
-	Channel_list []string
+	Channel_list []string // Lines of the channel list, see src.Parse_channel_entry
+	Channel_entries map[string]src.ChannelEntry
 
 	Cache LRU
+	About AboutCache
+	Player src.PlayerConfig
+	Settings src.Settings
 	Refresh_queue chan src.VideoPacket
 	Log_queue chan []byte
 
@@ -34,12 +51,52 @@ type UIState struct {
 	Follow_latest map[string]FollowPair
 	Follow_selection uint16
 	Follow_videos []src.Video
+	Raid_queue chan RaidPacket
+	Raids map[string]src.Raid // By the channel that raided, pending while it is live
+	Channel_ids map[string]string // By login, learned from refreshes, see src.CHANNEL_IDS_FILE
+	Channel_ids_dirty bool
+	Channel_states map[string]src.ChannelStatus // By login, only for channels that came back missing
+	Status_queue chan StatusPacket
+	Last_live map[string]src.LastBroadcast // By login, only recorded once loaded, see src.LAST_LIVE_FILE
+	Last_live_saved time.Time
+	Last_live_dirty bool
 
 	// Channel screen
 	Channel string
 	Channel_selection uint16
 	Channel_videos RingBuffer
 	Channel_command []byte
+	Channel_next map[string]string // Cursor of the next page of VODs, "" once we have every page
+	Channel_loading map[string]bool
+	Channel_filter string // One of src.BROADCAST_*, "" shows every type not hidden by the channel entry
+	Clip_from string // The start of the clip, the end is Channel_command
+	Clip_queue chan src.ClipProgress
+
+	// Category screen, and "category:<slug>" entries of the channel list
+	Category_entries map[string]src.ChannelEntry // By slug
+	Category_live map[string][]src.Video // By slug, most viewers first
+	Category_vods map[string][]src.Video // By slug
+	Category string // Slug, or the name if Category_is_name
+	Category_is_name bool
+	Category_selection uint16
+	Category_videos []src.Video
+
+	// Search screen
+	Search_queue chan SearchPacket
+	Search_input []byte
+	Search_is_typing bool
+	Search_results []src.SearchResult
+	Search_selection uint16
+
+	// About screen
+	About_queue chan AboutPacket
+	About_channel string
+	About_scroll int
+
+	// Schedule screen
+	Schedule_queue chan SchedulePacket
+	Schedule []src.ScheduleSegment
+	Schedule_selection uint16
 
 	Message strings.Builder
 }
@@ -77,15 +134,25 @@ func (self *UIState) Load_config(config string) {
 
 	// @TODO: Refactor this to work even when we run out of cache
 	//        Maybe this is resolved RingBuffer.Latest
+	if count > src.MAX_CHANNELS {
+		src.L_ERROR.Printf("The channel list has %d lines, ignoring the ones after the first %d", count, src.MAX_CHANNELS)
+		count = src.MAX_CHANNELS
+	}
 	src.Assert(count * src.PAGE_SIZE <= src.RING_QUEUE_SIZE)
 
 	self.Refresh_queue = make(chan src.VideoPacket, 100)
 	self.Log_queue = make(chan []byte, 100)
+	self.Search_queue = make(chan SearchPacket, 10)
+	self.About_queue = make(chan AboutPacket, 10)
+	self.Schedule_queue = make(chan SchedulePacket, 10)
+	self.Clip_queue = make(chan src.ClipProgress, 100)
+	self.Raid_queue = make(chan RaidPacket, 10)
+	self.Status_queue = make(chan StatusPacket, 10)
 
-	self.Follow_videos = set_len(self.Follow_videos, count)
+	self.Follow_videos = self.Follow_videos[:0]
 
-	self.Channel_list = list[:count]
-	self.Channel_videos.Buffer = set_len(self.Channel_videos.Buffer, count)
+	self.Channel_list = self.Channel_list[:0]
+	self.Channel_videos.Buffer = set_len(self.Channel_videos.Buffer, src.RING_QUEUE_SIZE)
 	self.Channel_command = set_len(self.Channel_command, 100)
 
 	self.Cache.Buffer = set_len(self.Cache.Buffer, src.RING_QUEUE_SIZE)
@@ -99,64 +166,333 @@ func (self *UIState) Load_config(config string) {
 	if self.Follow_latest == nil {
 		self.Follow_latest = make(map[string]FollowPair, count * 2)
 	}
+	if self.Raids == nil {
+		self.Raids = make(map[string]src.Raid)
+	}
+	if self.Channel_ids == nil {
+		self.Channel_ids = make(map[string]string, count * 2)
+		self.Channel_states = make(map[string]src.ChannelStatus)
+	}
+	if self.Channel_next == nil {
+		self.Channel_next = make(map[string]string, count * 2)
+	}
+	if self.Channel_loading == nil {
+		self.Channel_loading = make(map[string]bool)
+	}
+
+	if self.Channel_entries == nil {
+		self.Channel_entries = make(map[string]src.ChannelEntry, count * 2)
+	}
+	if self.Category_entries == nil {
+		self.Category_entries = make(map[string]src.ChannelEntry)
+		self.Category_live = make(map[string][]src.Video)
+		self.Category_vods = make(map[string][]src.Video)
+	}
 
-	for i, channel := range list[:count] {
-		blank := src.Video{
-			Channel: channel,
+	for _, line := range list[:count] {
+		self.add_entry(src.Parse_channel_entry(line))
+	}
+}
+
+// Returns false if we already follow it, e.g. when a channel is both in the
+// embedded list and in src.FOLLOW_FILE
+func (self *UIState) add_entry(entry src.ChannelEntry) bool {
+	if entry.Is_category() {
+		if _, ok := self.Category_entries[entry.Login]; ok {
+			return false
 		}
-		self.Follow_videos[i] = blank
+		self.Category_entries[entry.Login] = entry
+		self.Channel_list = append(self.Channel_list, entry.String())
+		return true
+	}
 
-		// @VOLATILE: Add_and_update_follow depends on this
-		self.Follow_latest[channel] = FollowPair{blank, blank}
+	channel := entry.Login
+	if _, ok := self.Channel_entries[channel]; ok {
+		return false
+	}
+	self.Channel_entries[channel] = entry
+	self.Channel_list = append(self.Channel_list, entry.String())
+
+	blank := src.Video{
+		Channel: channel,
 	}
+	self.Follow_videos = append(self.Follow_videos, blank)
+
+	// @VOLATILE: Add_and_update_follow depends on this
+	self.Follow_latest[channel] = FollowPair{blank, blank}
+	return true
+}
+
+// Follow at runtime. The embedded channel list cannot change without a
+// rebuild, so we remember it in src.FOLLOW_FILE instead.
+func (self *UIState) Follow(entry src.ChannelEntry) error {
+	if !self.add_entry(entry) {
+		return fmt.Errorf("Already following %s", entry.Login)
+	}
+	return src.Append_follow_line(entry.String())
 }
 
 const PACKETS_PER_REFRESH = 2
 
-func Refresh_channels(queue chan src.VideoPacket, channels ...string) {
-	for _, channel := range channels {
-		go func() {
-			vods, live := src.Graph_vods(channel)
-			if live.Is_live {
-				src.L_DEBUG.Printf("%s is live", live.Channel)
+// Channels that are not in the channel list go through the default provider
+func (self *UIState) Entry(channel string) src.ChannelEntry {
+	if entry, ok := self.Channel_entries[channel]; ok {
+		return entry
+	}
+	return src.ChannelEntry{Provider: src.DEFAULT_PROVIDER, Login: channel}
+}
+
+// Sends PACKETS_PER_REFRESH packets for every entry (VODs, then live status)
+func Refresh_channels(queue chan src.VideoPacket, entries ...string) {
+	// Group by provider so that batching providers get as many channels as possible
+	var order []string
+	groups := map[string][]string{}
+	for _, line := range entries {
+		entry := src.Parse_channel_entry(line)
+		if _, ok := groups[entry.Provider]; !ok {
+			order = append(order, entry.Provider)
+		}
+		groups[entry.Provider] = append(groups[entry.Provider], entry.Login)
+	}
+
+	for _, name := range order {
+		channels := groups[name]
+		if name == src.CATEGORY_PREFIX {
+			for _, slug := range channels {
+				Refresh_category(queue, slug, false)
+			}
+			continue
+		}
+		provider, err := src.Get_provider(name)
+		if err != nil {
+			for _, channel := range channels {
+				queue <- src.VideoPacket{Err: err, Channel: channel}
+				queue <- src.VideoPacket{Live: true, Err: err, Channel: channel}
+			}
+			continue
+		}
+
+		if batcher, ok := provider.(src.BatchProvider); ok {
+			batch_size := max(src.BATCH_SIZE, 1)
+			for start := 0; start < len(channels); start += batch_size {
+				batch := channels[start:min(start + batch_size, len(channels))]
+				go func() {
+					for _, packet := range batcher.Refresh(batch) {
+						queue <- packet
+					}
+				}()
+			}
+		} else {
+			for _, channel := range channels {
+				go func() { queue <- provider.Vods(channel, "", "") }()
+				go func() { queue <- provider.Live_status(channel) }()
 			}
-			queue <- vods
-			queue <- src.VideoPacket{[]src.Video{live}, true, nil}
-		}()
-		//go func() { queue <- src.Scrape_vods(channel) }()
-		//go func() { queue <- src.Scrape_live_status(channel) }()
+		}
+	}
+}
+
+// Only sends a single VOD packet, the live status is covered by Refresh_channels
+func Refresh_page(queue chan src.VideoPacket, entry src.ChannelEntry, cursor string, broadcast_type string) {
+	go func() {
+		if provider, err := entry.Get_provider(); err != nil {
+			queue <- src.VideoPacket{Err: err, Channel: entry.Login, Cursor: cursor}
+		} else {
+			queue <- provider.Vods(entry.Login, cursor, broadcast_type)
+		}
+	}()
+}
+
+// Also sends PACKETS_PER_REFRESH packets, the live streams then the VODs
+// The packets are keyed by `key`, a slug or a name if `is_name`
+func Refresh_category(queue chan src.VideoPacket, key string, is_name bool) {
+	go func() {
+		graph := src.Graph_category
+		if is_name {
+			graph = src.Graph_category_by_name
+		}
+		category, err := graph(key)
+		queue <- src.VideoPacket{Vids: category.Live, Live: true, Err: err, Category: key, Backend: "graphql"}
+		queue <- src.VideoPacket{Vids: category.Vods, Err: err, Category: key, Backend: "graphql"}
+	}()
+}
+
+// The order `t` cycles through on the channel screen
+var FILTER_CYCLE = []string{"", src.BROADCAST_ARCHIVE, src.BROADCAST_HIGHLIGHT, src.BROADCAST_UPLOAD}
+
+func Next_filter(current string) string {
+	idx := slices.Index(FILTER_CYCLE, current)
+	return FILTER_CYCLE[(idx + 1) % len(FILTER_CYCLE)]
+}
+
+// An explicit filter shows only that type, otherwise the entry decides
+func Is_filtered_out(entry src.ChannelEntry, filter string, video src.Video) bool {
+	if video.Is_live {
+		return false
+	} else if filter != "" {
+		return video.Broadcast_type != filter
+	} else {
+		return entry.Is_hidden(video)
 	}
 }
 
 func Print_formatted_line(output io.Writer, gap string, video src.Video) {
-	sizes := []int{10, 30, 9, 6}
+	sizes := []int{10, 30, 9, 6, 7}
 
 	var s_ago, title, duration string
 	t_ago := time.Now().Sub(video.Start_time)
 
 	if video.Start_time == (time.Time{}) {
 		title = "Pending..."
+		if video.Title != "" {
+			title = video.Title // e.g. why the channel has nothing, see src.ChannelStatus
+		} else if !video.Last_live.Is_zero() {
+			title = "last live " + Format_ago(time.Since(video.Last_live.Time))
+			if video.Last_live.Title != "" {
+				title += " · " + video.Last_live.Title
+			}
+		}
 	} else {
 		title = video.Title
+		if len(video.Muted) > 0 {
+			title = "🔇 " + title
+		}
+		if video.Old_title != "" {
+			title = "✎ " + title
+		}
+		if video.Change != src.VIDEO_LISTED {
+			title = "[" + src.Video_change_name(video.Change) + "] " + title
+		}
+		if video.Raided != "" && !video.Is_live {
+			title = "→ raided " + video.Raided + " · " + title
+		}
+		if video.Change == src.VIDEO_LISTED && src.Check_playable(video) != nil {
+			title = "🔒 " + title
+		}
+		if video.Stream_type != "" {
+			title = "[" + src.Stream_type_name(video.Stream_type) + "] " + title
+		}
 		if video.Is_live {
 			s_ago = "○"
-			duration = fmt.Sprintf("%dh%02dm", int(t_ago.Hours()), int(t_ago.Minutes()) % 60)
-		} else {
-			// @NOTE twitch streams are capped at 48 hours
-			if int(t_ago.Minutes()) < 100 {
-				s_ago = fmt.Sprintf("%d min ago", int(t_ago.Minutes()))
-			} else if int(t_ago.Hours()) < 72 {
-				s_ago = fmt.Sprintf("%d hr ago", int(t_ago.Hours()))
-			} else {
-				s_ago = fmt.Sprintf("%d d ago", int(t_ago.Hours() / 24))
+			if video.Viewers > 0 {
+				s_ago = "○ " + Format_count(video.Viewers)
 			}
+			duration = Format_hm(t_ago)
+		} else {
+			s_ago = Format_ago(t_ago)
 
-			duration = fmt.Sprintf("%dh%02dm", int(video.Duration.Hours()), int(video.Duration.Minutes()) % 60)
+			duration = Format_hm(video.Duration)
 		}
 	}
 
-	print_line(output, gap, sizes, []string{video.Channel, title, s_ago, duration})
+	print_line(output, gap, sizes, []string{video.Channel, title, s_ago, duration, video.Backend})
 }
+// e.g. "foo | Foo | 12k followers | ○ 1.2k | Playing chess"
+func Print_search_line(output io.Writer, gap string, result src.SearchResult, is_following bool) {
+	sizes := []int{15, 15, 15, 7, 30}
+
+	name := result.Channel.Login
+	if is_following {
+		name = "★ " + name
+	}
+	status := "offline"
+	if result.Live.Is_live {
+		status = "○ " + Format_count(result.Live.Viewers)
+	}
+	followers := Format_count(result.Followers) + " followers"
+	print_line(output, gap, sizes, []string{name, result.Channel.Display_name, followers, status, result.Live.Title})
+}
+
+// e.g. 950, 1.2k, 34k, 1.5M
+func Format_count(count int) string {
+	switch {
+	case count < 1000:
+		return fmt.Sprintf("%d", count)
+	case count < 10_000:
+		return fmt.Sprintf("%.1fk", float64(count) / 1000)
+	case count < 1_000_000:
+		return fmt.Sprintf("%dk", count / 1000)
+	default:
+		return fmt.Sprintf("%.1fM", float64(count) / 1_000_000)
+	}
+}
+
+// The lines below the list on the channel screen, skipping what we do not know
+func Format_details(video src.Video) []string {
+	var lines []string
+	var header []string
+	if video.Display_name != "" {
+		header = append(header, video.Display_name)
+	}
+	if video.Game != "" {
+		header = append(header, video.Game)
+	}
+	if video.Language != "" {
+		header = append(header, "[" + video.Language + "]")
+	}
+	if video.Is_live && video.Viewers > 0 {
+		header = append(header, fmt.Sprintf("%s viewers (peak %s)", Format_count(video.Viewers), Format_count(max(video.Peak_viewers, video.Viewers))))
+	} else if !video.Is_live && video.View_count > 0 {
+		header = append(header, Format_count(video.View_count) + " views")
+	}
+	if len(header) > 0 {
+		lines = append(lines, strings.Join(header, " | "))
+	}
+	if err := src.Check_playable(video); err != nil {
+		lines = append(lines, err.Error())
+	}
+	if video.Old_title != "" {
+		lines = append(lines, "Was titled: " + video.Old_title)
+	}
+	if len(video.Muted) > 0 {
+		lines = append(lines, fmt.Sprintf("Muted: %s in %d ranges, first at %s", Format_hm(video.Total_muted()), len(video.Muted), src.Format_offset(video.Muted[0].Offset)))
+	}
+	if len(video.Tags) > 0 {
+		lines = append(lines, "Tags: " + strings.Join(video.Tags, ", "))
+	}
+	if video.Description != "" {
+		// The description can span many lines, keep the layout predictable
+		lines = append(lines, strings.Join(strings.Fields(video.Description), " "))
+	}
+	return lines
+}
+
+// e.g. "graphql 12/0 230ms | scrape 0/3 1.2s (cooldown until 12:00:00)"
+func Format_health(report []src.BackendHealth) string {
+	parts := make([]string, len(report))
+	for i, x := range report {
+		parts[i] = fmt.Sprintf("%s %d/%d %s", x.Name, x.Success, x.Failure, x.Average_latency().Round(time.Millisecond))
+		if time.Now().Before(x.Cooldown_until) {
+			parts[i] += " (cooldown until " + x.Cooldown_until.Format(time.TimeOnly) + ")"
+		}
+	}
+	return strings.Join(parts, " | ")
+}
+
+// e.g. "5 min ago", "3 hr ago", "3 d ago"
+func Format_ago(t_ago time.Duration) string {
+	// @NOTE twitch streams are capped at 48 hours
+	if int(t_ago.Minutes()) < 100 {
+		return fmt.Sprintf("%d min ago", int(t_ago.Minutes()))
+	} else if int(t_ago.Hours()) < 72 {
+		return fmt.Sprintf("%d hr ago", int(t_ago.Hours()))
+	} else {
+		return fmt.Sprintf("%d d ago", int(t_ago.Hours() / 24))
+	}
+}
+
+func Format_hm(duration time.Duration) string {
+	return fmt.Sprintf("%dh%02dm", int(duration.Hours()), int(duration.Minutes()) % 60)
+}
+
+// e.g. "Elden Ring 2h10m | Just Chatting 0h40m"
+func Format_timeline(chapters []src.Chapter) string {
+	parts := make([]string, len(chapters))
+	for i, chapter := range chapters {
+		parts[i] = chapter.Name + " " + Format_hm(chapter.Duration)
+	}
+	return strings.Join(parts, " | ")
+}
+
 func print_line(output io.Writer, gap string, sizes []int, cols []string) error {
 	if len(sizes) != len(cols) {
 		src.L_ERROR.Fatalf("Incorrect number of arguments")
@@ -262,20 +598,375 @@ func is_ASCII(s string) bool {
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// Followed channels, then the top live streams of followed categories from
+// channels that we do not already follow
+func (self *UIState) Build_follow_videos() {
+	self.Follow_videos = self.Follow_videos[:0]
+	// @VOLATILE: Load_config seeds the keys
+	for channel, pair := range self.Follow_latest {
+		if pair.Live.Duration > 0 && !(src.Is_rerun(pair.Live) && self.Settings.Reruns == src.RERUNS_HIDE) {
+			self.Follow_videos = append(self.Follow_videos, pair.Live)
+		} else {
+			vid := pair.Latest
+			if raid, ok := self.Active_raid(channel); ok {
+				vid.Raided = raid.Target
+			}
+			if status, ok := self.Channel_states[channel]; ok && vid.Start_time.IsZero() {
+				vid.Title = status.String()
+			}
+			if vid.Start_time.IsZero() {
+				vid.Last_live = src.Latest_broadcast(pair.Live.Last_live, self.Last_live[channel])
+			}
+			self.Follow_videos = append(self.Follow_videos, vid)
+		}
+	}
+
+	seen := map[string]bool{}
+	for slug, entry := range self.Category_entries {
+		count := 0
+		for _, vid := range self.Category_live[slug] {
+			if count >= entry.Top {
+				break
+			}
+			if _, ok := self.Follow_latest[vid.Channel]; ok || seen[vid.Channel] {
+				continue
+			}
+			seen[vid.Channel] = true
+			self.Follow_videos = append(self.Follow_videos, vid)
+			count += 1
+		}
+	}
+	slices.SortFunc(self.Follow_videos, self.Settings.Sort_follow())
+}
+
+// Channels we do not follow can still be live through a category
+func (self *UIState) Live_video(channel string) (src.Video, bool) {
+	if pair, ok := self.Follow_latest[channel]; ok {
+		return pair.Live, pair.Live.Duration > 0
+	}
+	for _, videos := range self.Category_live {
+		for _, vid := range videos {
+			if vid.Channel == channel {
+				return vid, true
+			}
+		}
+	}
+	for _, result := range self.Search_results {
+		if result.Live.Channel == channel && result.Live.Is_live {
+			return result.Live, true
+		}
+	}
+	return src.Video{}, false
+}
+
+func (self *UIState) Is_following(channel string) bool {
+	_, ok := self.Channel_entries[channel]
+	return ok
+}
+
+// True if `packet` says that a followed channel we saw live is now offline
+func (self *UIState) Stream_ended(packet src.VideoPacket) bool {
+	if !packet.Live || packet.Category != "" || len(packet.Vids) != 1 {
+		return false
+	}
+	pair, ok := self.Follow_latest[packet.Vids[0].Channel]
+	return ok && pair.Live.Is_live && !packet.Vids[0].Is_live
+}
+
+// Keeps the latest raid a live channel is counting down to, and forgets it if
+// the raid was called off. Once the raid goes through it is no longer active,
+// so an offline channel keeps its pending raid for Stream_ended.
+func (self *UIState) Add_raid(raid src.Raid) {
+	if pair, ok := self.Follow_latest[raid.Channel]; !ok || !pair.Live.Is_live {
+		return // Too late, Stream_ended already went by
+	}
+	if raid.Target != "" {
+		self.Raids[raid.Channel] = raid
+	} else if raid.Is_live {
+		delete(self.Raids, raid.Channel)
+	}
+}
+
+// The followed channels to ask about raids, see RAID_POLL_INTERVAL
+func (self *UIState) Live_channels() []string {
+	var channels []string
+	for channel, pair := range self.Follow_latest {
+		if pair.Live.Is_live {
+			channels = append(channels, channel)
+		}
+	}
+	slices.Sort(channels)
+	return channels
+}
+
+// The raid is only shown for src.Settings.Raid_minutes, and not once the
+// channel is live again
+func (self *UIState) Active_raid(channel string) (src.Raid, bool) {
+	raid, ok := self.Raids[channel]
+	if !ok || raid.Target == "" || time.Since(raid.Time) >= self.Settings.Raid_duration() {
+		return src.Raid{}, false
+	}
+	if pair, ok := self.Follow_latest[channel]; ok && pair.Live.Is_live {
+		return src.Raid{}, false
+	}
+	return raid, true
+}
+
+func (self *UIState) record_live(vid src.Video) {
+	if self.Last_live == nil {
+		return
+	}
+	self.Last_live[vid.Channel] = src.LastBroadcast{Time: time.Now(), Title: vid.Title}
+	self.Last_live_dirty = true
+	if time.Since(self.Last_live_saved) >= src.LAST_LIVE_SAVE_INTERVAL {
+		self.Save_last_live()
+	}
+}
+
+// Only writes when we learned something new
+func (self *UIState) Save_channel_ids() {
+	if !self.Channel_ids_dirty {
+		return
+	}
+	self.Channel_ids_dirty = false
+	if err := src.Save_channel_ids(self.Channel_ids); err != nil {
+		src.L_ERROR.Printf("Could not save %s: %s", src.CHANNEL_IDS_FILE, err)
+	}
+}
+
+func (self *UIState) Save_last_live() {
+	if self.Last_live == nil || !self.Last_live_dirty {
+		return
+	}
+	self.Last_live_dirty = false
+	self.Last_live_saved = time.Now()
+	if err := src.Save_last_live(self.Last_live); err != nil {
+		src.L_ERROR.Printf("Could not save %s: %s", src.LAST_LIVE_FILE, err)
+	}
+}
+
+type StatusPacket struct {
+	Status src.ChannelStatus
+	Err    error
+}
+
+// Only worth asking when a refresh says the channel does not exist
+func (self *UIState) Needs_status(packet src.VideoPacket) bool {
+	_, is_missing := packet.Err.(src.ErrMissing)
+	return is_missing && !packet.Live && packet.Category == "" && self.Is_following(packet.Channel)
+}
+
+func (self *UIState) Refresh_status(channel string) {
+	entry := self.Entry(channel)
+	if entry.Id == "" {
+		entry.Id = self.Channel_ids[channel]
+	}
+	go func() {
+		status, err := src.Graph_channel_status(entry)
+		self.Status_queue <- StatusPacket{Status: status, Err: err}
+	}()
+}
+
+// Writes the new logins of renamed channels to src.FOLLOW_FILE, then follows
+// them by their new login. A renamed channel of the built-in list gets a new
+// line there, as we cannot rebuild.
+// Returns the lines of the renamed channels, which have not been refreshed.
+func (self *UIState) Rewrite_channels() (int, []string, error) {
+	renames := map[string]string{}
+	for channel, status := range self.Channel_states {
+		if status.State == src.CHANNEL_RENAMED {
+			renames[channel] = status.Login
+		}
+	}
+
+	followed, err := src.Load_follow_file()
+	if err != nil {
+		return 0, nil, err
+	}
+	var entries []src.ChannelEntry
+	in_file := map[string]bool{}
+	for line := range strings.SplitSeq(followed, "\n") {
+		if strings.TrimSpace(line) != "" {
+			entry := src.Parse_channel_entry(line)
+			in_file[entry.Login] = true
+			entries = append(entries, entry)
+		}
+	}
+	changed := src.Rewrite_entries(entries, renames)
+	for _, old := range slices.Sorted(maps.Keys(renames)) {
+		if !in_file[old] {
+			entry := self.Entry(old)
+			entry.Login = renames[old]
+			entries = append(entries, entry)
+			changed += 1
+		}
+	}
+	if changed == 0 {
+		return 0, nil, nil
+	}
+	if err := src.Save_follow_file(entries); err != nil {
+		return 0, nil, err
+	}
+
+	var renamed []string
+	for _, old := range slices.Sorted(maps.Keys(renames)) {
+		if line, ok := self.rename_entry(old, renames[old]); ok {
+			renamed = append(renamed, line)
+		}
+	}
+	return changed, renamed, nil
+}
+
+func (self *UIState) rename_entry(old string, login string) (string, bool) {
+	entry := self.Entry(old)
+	self.Channel_list = slices.DeleteFunc(self.Channel_list, func(line string) bool {
+		return line == entry.String()
+	})
+	delete(self.Channel_entries, old)
+	delete(self.Follow_latest, old)
+	delete(self.Channel_states, old)
+
+	entry.Login = login
+	if id, ok := self.Channel_ids[old]; ok {
+		delete(self.Channel_ids, old)
+		self.Channel_ids[login] = id
+		self.Channel_ids_dirty = true
+		self.Save_channel_ids()
+	}
+	return entry.String(), self.add_entry(entry)
+}
+
+type RaidPacket struct {
+	Raid src.Raid
+	Err  error
+}
+
+// A raid is only announced for about 90 seconds before it goes through
+const RAID_POLL_INTERVAL = 30 * time.Second
+
+func Refresh_raids(queue chan RaidPacket, channels []string) {
+	go func() {
+		raids, errs := src.Graph_raids(channels, time.Now())
+		for i := range channels {
+			queue <- RaidPacket{Raid: raids[i], Err: errs[i]}
+		}
+	}()
+}
+
+// Diffs a page of VODs against the Cache. The page only speaks for the time
+// range it covers, and for everything older once there is no next page.
+// Retitled videos of the page keep the title we saw first, and the cached
+// videos that are gone are marked. Returns the ones that were newly marked.
+func (self *UIState) Track_changes(packet src.VideoPacket) []src.Video {
+	if packet.Channel == "" || packet.Err != nil || packet.Live || packet.Category != "" {
+		return nil
+	}
+	if len(packet.Vids) == 0 && (packet.Cursor != "" || packet.Next != "") {
+		return nil
+	}
+
+	listed := make(map[string]bool, len(packet.Vids))
+	var oldest, newest time.Time
+	for i, vid := range packet.Vids {
+		listed[vid.Url] = true
+		if i == 0 || vid.Start_time.Before(oldest) {
+			oldest = vid.Start_time
+		}
+		if i == 0 || vid.Start_time.After(newest) {
+			newest = vid.Start_time
+		}
+		if j, ok := self.Cache.Exists[vid.Url]; ok && self.Cache.Buffer[j].Url == vid.Url {
+			known := self.Cache.Buffer[j]
+			packet.Vids[i].Old_title = known.Old_title
+			if known.Old_title == "" && known.Title != vid.Title {
+				packet.Vids[i].Old_title = known.Title
+			}
+		}
+	}
+
+	if !packet.Complete {
+		return nil
+	}
+
+	var changed []src.Video
+	for i, vid := range self.Cache.As_slice() {
+		if vid.Channel != packet.Channel || vid.Is_live || listed[vid.Url] || vid.Change != src.VIDEO_LISTED {
+			continue
+		}
+		if packet.Broadcast_type != "" && vid.Broadcast_type != packet.Broadcast_type {
+			continue
+		}
+		is_older := len(packet.Vids) == 0 || vid.Start_time.Before(oldest)
+		if (packet.Cursor != "" && vid.Start_time.After(newest)) || (packet.Next != "" && is_older) {
+			continue // Not on this page
+		}
+
+		vid.Change = src.VIDEO_REMOVED
+		// Only past broadcasts expire, and they go from the end of the list
+		if is_older && vid.Broadcast_type == src.BROADCAST_ARCHIVE {
+			vid.Change = src.VIDEO_EXPIRED
+		}
+		self.Cache.Buffer[i] = vid
+		if pair, ok := self.Follow_latest[vid.Channel]; ok && pair.Latest.Url == vid.Url {
+			pair.Latest.Change = vid.Change
+			self.Follow_latest[vid.Channel] = pair
+		}
+		changed = append(changed, vid)
+	}
+	return changed
+}
+
 // Live packets are only stored in self.Follow_latest, not in the Cache.
 func (self *UIState) Add_and_update_follow(packet src.VideoPacket) {
+	if packet.Category != "" {
+		if packet.Live {
+			self.Category_live[packet.Category] = packet.Vids
+		} else {
+			self.Category_vods[packet.Category] = packet.Vids
+			self.Cache.Merge(packet.Vids)
+		}
+		return
+	}
+
 	if packet.Live {
 		src.Assert(len(packet.Vids) == 1)
 		vid := packet.Vids[0]
+		raid, has_raid := self.Raids[vid.Channel]
+		if self.Stream_ended(packet) {
+			self.Save_last_live()
+			if has_raid {
+				// Shown for src.Settings.Raid_minutes from here
+				raid.Time = time.Now()
+				self.Raids[vid.Channel] = raid
+				_, _ = self.Message.WriteString(fmt.Sprintf("%s raided %s\n", raid.Channel, raid.Target))
+			}
+		} else if vid.Is_live {
+			self.record_live(vid)
+			if pair, ok := self.Follow_latest[vid.Channel]; has_raid && ok && !pair.Live.Is_live {
+				delete(self.Raids, vid.Channel) // From the previous stream
+			}
+		} else if has_raid && time.Since(raid.Time) >= self.Settings.Raid_duration() {
+			delete(self.Raids, vid.Channel)
+		}
 		if las, ok := self.Follow_latest[vid.Channel]; ok {
+			// Twitch only tells us the current viewers, so keep the peak ourselves
+			if vid.Is_live && las.Live.Is_live && src.Is_similar_time(vid.Start_time, las.Live.Start_time) {
+				vid.Peak_viewers = max(vid.Peak_viewers, vid.Viewers, las.Live.Peak_viewers)
+			}
 			self.Follow_latest[vid.Channel] = FollowPair{vid, las.Latest}
 		}
 	} else {
+		for _, vid := range self.Track_changes(packet) {
+			line := fmt.Sprintf("%s %s %s %q %s", time.Now().Format(time.RFC3339), src.Video_change_name(vid.Change), vid.Channel, vid.Title, vid.Url)
+			if err := src.Append_config_line(src.VOD_LOG_FILE, line); err != nil {
+				src.L_ERROR.Printf("Could not log to %s: %s", src.VOD_LOG_FILE, err)
+			}
+			_, _ = self.Message.WriteString(fmt.Sprintf("%s: %q was %s\n", vid.Channel, vid.Title, src.Video_change_name(vid.Change)))
+		}
+		self.Cache.Merge(packet.Vids)
 		for _, vid := range packet.Vids {
-			self.Cache.Push(vid)
-
 			// If one of the channels we follow
-			if pair, ok := self.Follow_latest[vid.Channel]; ok {
+			if pair, ok := self.Follow_latest[vid.Channel]; ok && !self.Entry(vid.Channel).Is_hidden(vid) {
 				las := pair.Latest
 				vid_close_time := vid.Start_time.Add(vid.Duration)
 				las_close_time := las.Start_time.Add(las.Duration)
@@ -289,8 +980,24 @@ func (self *UIState) Add_and_update_follow(packet src.VideoPacket) {
 				self.Follow_latest[vid.Channel] = pair
 			}
 		}
-	}
 
+		if packet.User_id != "" && packet.Channel != "" {
+			if self.Channel_ids[packet.Channel] != packet.User_id {
+				self.Channel_ids[packet.Channel] = packet.User_id
+				self.Channel_ids_dirty = true
+			}
+			delete(self.Channel_states, packet.Channel)
+		}
+
+		// A refresh re-requests the first page, so do not lose how far we have paged
+		if packet.Channel != "" {
+			// A cursor only pages through the type it was requested with
+			if next, ok := self.Channel_next[packet.Channel]; packet.Broadcast_type == self.Channel_filter && (!ok || next == packet.Cursor) {
+				self.Channel_next[packet.Channel] = packet.Next
+			}
+			delete(self.Channel_loading, packet.Channel)
+		}
+	}
 }
 
 
@@ -359,6 +1066,18 @@ func (self *LRU) Push(video src.Video) {
 	self.Close = ((self.Close + 1) % length) + to_add
 }
 
+// Consecutive pages overlap if a VOD is published between requests, so update
+// videos we already have in place rather than invalidating and re-adding them
+func (self *LRU) Merge(videos []src.Video) {
+	for _, vid := range videos {
+		if i, ok := self.Exists[vid.Url]; ok && self.Buffer[i].Url == vid.Url {
+			self.Buffer[i] = vid
+		} else {
+			self.Push(vid)
+		}
+	}
+}
+
 func (self *LRU) As_slice() []src.Video {
 	if self.Close <= len(self.Buffer) {
 		return self.Buffer[:self.Close]
@@ -367,3 +1086,181 @@ func (self *LRU) As_slice() []src.Video {
 	}
 }
 
+
+// Channel info rarely changes, so unlike videos we only refetch it once it is
+// older than ABOUT_TTL. Failures are kept too, so that we do not hammer twitch.
+const ABOUT_TTL = time.Hour
+
+type AboutEntry struct {
+	Info       src.ChannelInfo
+	Err        error
+	Fetched_at time.Time
+}
+
+type AboutCache struct {
+	Entries map[string]AboutEntry
+}
+
+func (self *AboutCache) Get(channel string) (AboutEntry, bool) {
+	entry, ok := self.Entries[channel]
+	if !ok || time.Now().Sub(entry.Fetched_at) > ABOUT_TTL {
+		return entry, false
+	}
+	return entry, true
+}
+
+func (self *AboutCache) Put(channel string, info src.ChannelInfo, err error) {
+	if self.Entries == nil {
+		self.Entries = make(map[string]AboutEntry)
+	}
+	self.Entries[channel] = AboutEntry{Info: info, Err: err, Fetched_at: time.Now()}
+}
+
+type AboutPacket struct {
+	Channel string
+	Info    src.ChannelInfo
+	Err     error
+}
+
+func Refresh_about(queue chan AboutPacket, entry src.ChannelEntry) {
+	go func() {
+		if provider, err := entry.Get_provider(); err != nil {
+			queue <- AboutPacket{Channel: entry.Login, Err: err}
+		} else {
+			info, err := provider.Channel_info(entry.Login)
+			queue <- AboutPacket{Channel: entry.Login, Info: info, Err: err}
+		}
+	}()
+}
+
+// The body of the About screen, one string per line
+func Format_about(info src.ChannelInfo) []string {
+	var lines []string
+	header := info.Display_name
+	if info.Is_partner {
+		header += " (Partner)"
+	} else if info.Is_affiliate {
+		header += " (Affiliate)"
+	}
+	header += " | " + Format_count(info.Followers) + " followers"
+	if info.Team != "" {
+		header += " | Team " + info.Team
+	}
+	lines = append(lines, header)
+	if info.Description != "" {
+		lines = append(lines, "")
+		for line := range strings.SplitSeq(strings.TrimSpace(info.Description), "\n") {
+			lines = append(lines, strings.TrimRight(line, "\r "))
+		}
+	}
+
+	if len(info.Socials) > 0 {
+		lines = append(lines, "")
+		for _, x := range info.Socials {
+			lines = append(lines, fmt.Sprintf("%s: %s", x.Title, x.Url))
+		}
+	}
+
+	for _, x := range info.Panels {
+		lines = append(lines, "")
+		if x.Title != "" {
+			lines = append(lines, "## " + x.Title)
+		}
+		for line := range strings.SplitSeq(strings.TrimSpace(x.Description), "\n") {
+			lines = append(lines, strings.TrimRight(line, "\r "))
+		}
+		if x.Link_URL != "" {
+			lines = append(lines, "-> " + x.Link_URL)
+		}
+	}
+	return lines
+}
+
+type SchedulePacket struct {
+	Segments []src.ScheduleSegment // Already merged
+	Errs     []error
+}
+
+// Only GraphQL knows the schedule, whatever the provider of the entry
+func (self *UIState) Schedule_channels() []string {
+	channels := make([]string, 0, len(self.Channel_entries))
+	for channel := range self.Channel_entries {
+		channels = append(channels, channel)
+	}
+	slices.Sort(channels)
+	return channels
+}
+
+func Refresh_schedule(queue chan SchedulePacket, channels []string) {
+	go func() {
+		segments, errs := src.Graph_schedules(channels, time.Now())
+		var failed []error
+		for _, err := range errs {
+			if err != nil {
+				failed = append(failed, err)
+			}
+		}
+		queue <- SchedulePacket{Segments: src.Merge_schedules(segments), Errs: failed}
+	}()
+}
+
+// e.g. "Sat 18 14:00 | foo | Speedrun | Elden Ring | in 3 hr"
+func Print_schedule_line(output io.Writer, gap string, segment src.ScheduleSegment, now time.Time) {
+	sizes := []int{12, 10, 30, 15, 14}
+
+	var status string
+	until := segment.Start.Sub(now)
+	switch {
+	case segment.Is_vacation:
+		status = "until " + segment.End.Local().Format("Jan 2")
+	case segment.Is_cancelled:
+		status = "cancelled"
+	case until <= 0:
+		status = "now"
+	case until < 100 * time.Minute:
+		status = fmt.Sprintf("in %d min", int(until.Minutes()))
+	case until < 72 * time.Hour:
+		status = fmt.Sprintf("in %d hr", int(until.Hours()))
+	default:
+		status = fmt.Sprintf("in %d d", int(until.Hours() / 24))
+	}
+	when := segment.Start.Local().Format("Mon 02 15:04")
+	print_line(output, gap, sizes, []string{when, segment.Channel, segment.Title, segment.Category, status})
+}
+
+// e.g. "123-1h2m0s-1h12m30s.ts" in the current directory
+func Clip_name(vod_id string, from time.Duration, to time.Duration) string {
+	return fmt.Sprintf("%s-%s-%s.ts", vod_id, from, to)
+}
+
+// Progress goes to `queue` so that the main loop can show it
+func Start_clip(queue chan src.ClipProgress, entry src.ChannelEntry, video src.Video, quality string, from_str string, to_str string) (string, error) {
+	id, err := src.Vod_id(video)
+	if err != nil {
+		return "", err
+	}
+	provider, err := entry.Get_provider()
+	if err != nil {
+		return "", err
+	}
+	from, err := src.Parse_offset(from_str)
+	if err != nil {
+		return "", err
+	}
+	to, err := src.Parse_offset(to_str)
+	if err != nil {
+		return "", err
+	}
+	output := Clip_name(id, from, to)
+	go func() {
+		err := src.Clip(context.Background(), provider, video, quality, from, to, output, func(x src.ClipProgress) {
+			// Drop progress rather than block the download on a busy UI
+			select {
+			case queue <- x:
+			default:
+			}
+		})
+		queue <- src.ClipProgress{Path: output, Done: -1, Err: err}
+	}()
+	return output, nil
+}
@@ -1,7 +1,12 @@
 package tui
 
 import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/yueleshia/streamsurf/src"
 	a "github.com/yueleshia/streamsurf/src/testify"
@@ -12,7 +17,7 @@ import (
 
 func lru(size int) LRU {
 	return LRU {
-		Buffer: make([]src.Video, size),
+		RingBuffer: RingBuffer { Buffer: make([]src.Video, size) },
 		Exists: make(map[string]int, size * 2),
 	}
 }
@@ -48,3 +53,321 @@ func TestWrap(t *testing.T) {
 	a.AssertEqual(t, src.Video{}, cache.Buffer[2])
 }
 
+
+func TestMerge(t *testing.T) {
+	var cache = lru(10)
+	cache.Merge([]src.Video{{ Url: "a" }, { Url: "b" }})
+	cache.Merge([]src.Video{{ Url: "b", Title: "new" }, { Url: "c" }})
+	a.AssertEqual(t, src.Video{ Url: "a" }, cache.Buffer[0])
+	a.AssertEqual(t, src.Video{ Url: "b", Title: "new" }, cache.Buffer[1])
+	a.AssertEqual(t, src.Video{ Url: "c" }, cache.Buffer[2])
+	a.AssertEqual(t, 3, cache.Close)
+}
+
+func TestFormatCount(t *testing.T) {
+	a.AssertEqual(t, "950", Format_count(950))
+	a.AssertEqual(t, "1.2k", Format_count(1234))
+	a.AssertEqual(t, "34k", Format_count(34_567))
+	a.AssertEqual(t, "1.5M", Format_count(1_500_000))
+}
+
+func TestPeakViewers(t *testing.T) {
+	ui := UIState{}
+	ui.Load_config("foo")
+	start := time.Now().Add(-time.Hour)
+	live := func(viewers int) src.VideoPacket {
+		return src.VideoPacket{Live: true, Channel: "foo", Vids: []src.Video{{
+			Channel: "foo", Is_live: true, Start_time: start, Viewers: viewers, Peak_viewers: viewers,
+		}}}
+	}
+	ui.Add_and_update_follow(live(100))
+	ui.Add_and_update_follow(live(300))
+	ui.Add_and_update_follow(live(200))
+	a.AssertEqual(t, 200, ui.Follow_latest["foo"].Live.Viewers)
+	a.AssertEqual(t, 300, ui.Follow_latest["foo"].Live.Peak_viewers)
+}
+
+func TestFollowCategories(t *testing.T) {
+	ui := UIState{}
+	ui.Load_config("foo\ncategory:chess top=2")
+	a.AssertEqual(t, 1, len(ui.Follow_latest))
+
+	start := time.Now().Add(-time.Hour)
+	stream := func(channel string, viewers int) src.Video {
+		return src.Video{Channel: channel, Is_live: true, Start_time: start, Duration: time.Hour, Viewers: viewers}
+	}
+	ui.Add_and_update_follow(src.VideoPacket{Live: true, Category: "chess", Vids: []src.Video{
+		stream("foo", 300), stream("bar", 200), stream("baz", 100), stream("qux", 50),
+	}})
+	ui.Build_follow_videos()
+
+	// foo is already followed, so it does not count towards the top 2
+	channels := []string{}
+	for _, vid := range ui.Follow_videos {
+		channels = append(channels, vid.Channel)
+	}
+	slices.Sort(channels)
+	a.AssertEqual(t, []string{"bar", "baz", "foo"}, channels)
+
+	live, ok := ui.Live_video("baz")
+	a.AssertEqual(t, true, ok)
+	a.AssertEqual(t, 100, live.Viewers)
+}
+
+func TestFollow(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	ui := UIState{}
+	ui.Load_config("foo\nbar\nfoo")
+	a.AssertEqual(t, []string{"twitch:foo", "twitch:bar"}, ui.Channel_list)
+
+	a.AssertEqual(t, true, ui.Follow(src.ChannelEntry{Provider: src.DEFAULT_PROVIDER, Login: "baz"}) == nil)
+	a.AssertEqual(t, true, ui.Follow(src.ChannelEntry{Provider: src.DEFAULT_PROVIDER, Login: "foo"}) != nil)
+	a.AssertEqual(t, true, ui.Is_following("baz"))
+
+	followed, err := src.Load_follow_file()
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, "twitch:baz\n", followed)
+}
+
+func TestChannelLimit(t *testing.T) {
+	lines := make([]string, src.MAX_CHANNELS + 5)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("user%d", i)
+	}
+	ui := UIState{}
+	ui.Load_config(strings.Join(lines, "\n"))
+	a.AssertEqual(t, src.MAX_CHANNELS, len(ui.Channel_list))
+}
+
+func TestAboutCache(t *testing.T) {
+	var cache AboutCache
+	_, ok := cache.Get("foo")
+	a.AssertEqual(t, false, ok)
+
+	cache.Put("foo", src.ChannelInfo{Login: "foo", Display_name: "Foo", Followers: 1200, Is_partner: true, Description: "Hi\r\nI stream"}, nil)
+	entry, ok := cache.Get("foo")
+	a.AssertEqual(t, true, ok)
+	a.AssertEqual(t, []string{"Foo (Partner) | 1.2k followers", "", "Hi", "I stream"}, Format_about(entry.Info))
+
+	entry.Fetched_at = time.Now().Add(-2 * ABOUT_TTL)
+	cache.Entries["foo"] = entry
+	_, ok = cache.Get("foo")
+	a.AssertEqual(t, false, ok)
+}
+
+func TestRaid(t *testing.T) {
+	ui := UIState{Settings: src.DEFAULT_SETTINGS}
+	ui.Load_config("foo")
+	start := time.Now().Add(-time.Hour)
+	live := src.VideoPacket{Live: true, Channel: "foo", Vids: []src.Video{{Channel: "foo", Is_live: true, Start_time: start, Duration: time.Hour}}}
+	offline := src.VideoPacket{Live: true, Channel: "foo", Vids: []src.Video{{Channel: "foo"}}}
+
+	a.AssertEqual(t, false, ui.Stream_ended(offline))
+	ui.Add_raid(src.Raid{Channel: "foo", Target: "bar", Time: start})
+	a.AssertEqual(t, 0, len(ui.Raids)) // Not live
+	ui.Add_and_update_follow(live)
+	a.AssertEqual(t, false, ui.Stream_ended(live))
+
+	a.AssertEqual(t, []string{"foo"}, ui.Live_channels())
+
+	// Called off, then raiding someone else
+	ui.Add_raid(src.Raid{Channel: "foo", Target: "baz", Is_live: true, Time: start})
+	ui.Add_raid(src.Raid{Channel: "foo", Is_live: true, Time: start})
+	a.AssertEqual(t, 0, len(ui.Raids))
+	ui.Add_raid(src.Raid{Channel: "foo", Target: "bar", Is_live: true, Time: start})
+	ui.Build_follow_videos()
+	a.AssertEqual(t, "", ui.Follow_videos[0].Raided)
+
+	// Went through before the refresh noticed the stream ended
+	ui.Add_raid(src.Raid{Channel: "foo", Time: start})
+	a.AssertEqual(t, "bar", ui.Raids["foo"].Target)
+
+	a.AssertEqual(t, true, ui.Stream_ended(offline))
+	ui.Add_and_update_follow(offline)
+	ui.Build_follow_videos()
+	a.AssertEqual(t, "bar", ui.Follow_videos[0].Raided)
+
+	// The next stream does not inherit it
+	ui.Add_and_update_follow(live)
+	a.AssertEqual(t, 0, len(ui.Raids))
+
+	ui.Add_and_update_follow(offline)
+	ui.Raids["foo"] = src.Raid{Channel: "foo", Target: "bar", Time: time.Now().Add(-ui.Settings.Raid_duration())}
+	ui.Build_follow_videos()
+	a.AssertEqual(t, "", ui.Follow_videos[0].Raided)
+	ui.Add_and_update_follow(offline)
+	a.AssertEqual(t, 0, len(ui.Raids))
+}
+
+func TestRename(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	a.AssertEqual(t, nil, src.Append_follow_line("twitch:foo"))
+
+	ui := UIState{}
+	ui.Load_config("built\nfoo")
+	ui.Add_and_update_follow(src.VideoPacket{Channel: "foo", User_id: "1"})
+	ui.Channel_states["built"] = src.ChannelStatus{Channel: "built", Id: "2", State: src.CHANNEL_RENAMED, Login: "renamed"}
+	ui.Channel_ids["built"] = "2"
+	ui.Channel_ids_dirty = true
+	ui.Build_follow_videos()
+	titles := []string{}
+	for _, vid := range ui.Follow_videos {
+		titles = append(titles, vid.Title)
+	}
+	slices.Sort(titles)
+	a.AssertEqual(t, []string{"", "renamed to renamed"}, titles)
+
+	// Learned IDs are kept without pressing w
+	ui.Save_channel_ids()
+	a.AssertEqual(t, map[string]string{"foo": "1", "built": "2"}, src.Load_channel_ids())
+
+	changed, renamed, err := ui.Rewrite_channels()
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, 1, changed)
+	a.AssertEqual(t, []string{"twitch:renamed"}, renamed)
+	a.AssertEqual(t, []string{"twitch:foo", "twitch:renamed"}, ui.Channel_list)
+	a.AssertEqual(t, false, ui.Is_following("built"))
+	a.AssertEqual(t, map[string]string{"foo": "1", "renamed": "2"}, src.Load_channel_ids())
+
+	followed, err := src.Load_follow_file()
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, "twitch:foo\ntwitch:renamed\n", followed)
+}
+
+func TestVodChanges(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	ui := UIState{}
+	ui.Load_config("foo")
+	now := time.Now()
+	vod := func(id string, title string, days int, ty string) src.Video {
+		return src.Video{Channel: "foo", Url: id, Title: title, Start_time: now.Add(-time.Duration(days) * 24 * time.Hour), Broadcast_type: ty}
+	}
+	ui.Add_and_update_follow(src.VideoPacket{Channel: "foo", Complete: true, Vids: []src.Video{
+		vod("1", "one", 1, src.BROADCAST_ARCHIVE),
+		vod("2", "two", 2, src.BROADCAST_ARCHIVE),
+		vod("3", "three", 3, src.BROADCAST_UPLOAD),
+		vod("4", "four", 9, src.BROADCAST_ARCHIVE),
+	}})
+
+	// A second page that does not exist yet says nothing about the first
+	a.AssertEqual(t, 0, len(ui.Track_changes(src.VideoPacket{Channel: "foo", Complete: true, Cursor: "c", Vids: []src.Video{vod("5", "five", 20, src.BROADCAST_ARCHIVE)}})))
+
+	// 2 was deleted, 4 fell off the end, and 1 was retitled
+	ui.Add_and_update_follow(src.VideoPacket{Channel: "foo", Complete: true, Vids: []src.Video{
+		vod("1", "one!", 1, src.BROADCAST_ARCHIVE),
+		vod("3", "three", 3, src.BROADCAST_UPLOAD),
+	}})
+	ui.Add_and_update_follow(src.VideoPacket{Channel: "foo", Complete: true, Vids: []src.Video{
+		vod("1", "one!!", 1, src.BROADCAST_ARCHIVE),
+		vod("3", "three", 3, src.BROADCAST_UPLOAD),
+	}})
+
+	changes := map[string]int{}
+	titles := map[string]string{}
+	for _, vid := range ui.Cache.As_slice() {
+		changes[vid.Url] = vid.Change
+		titles[vid.Url] = vid.Old_title
+	}
+	a.AssertEqual(t, map[string]int{"1": src.VIDEO_LISTED, "2": src.VIDEO_REMOVED, "3": src.VIDEO_LISTED, "4": src.VIDEO_EXPIRED}, changes)
+	a.AssertEqual(t, "one", titles["1"])
+	removed := ui.Cache.Buffer[ui.Cache.Exists["2"]]
+	a.AssertEqual(t, `Cannot play "two", it is no longer on twitch (removed)`, src.Check_playable(removed).Error())
+
+	log, err := src.Config_path(src.VOD_LOG_FILE)
+	a.AssertEqual(t, nil, err)
+	data, err := os.ReadFile(log)
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, 2, strings.Count(string(data), "\n"))
+}
+
+func TestVodChangesScrape(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	ui := UIState{}
+	ui.Load_config("foo")
+	now := time.Now()
+	vods := make([]src.Video, 20)
+	for i := range vods {
+		vods[i] = src.Video{Channel: "foo", Url: fmt.Sprint(i), Title: "vod", Start_time: now.Add(-time.Duration(i + 1) * time.Hour), Broadcast_type: src.BROADCAST_ARCHIVE}
+	}
+	ui.Add_and_update_follow(src.VideoPacket{Channel: "foo", Backend: "graphql", Complete: true, Vids: slices.Clone(vods)})
+
+	// Scraping only sees the latest 10 and the 5th of them is gone
+	scraped := slices.Delete(slices.Clone(vods[:10]), 5, 6)
+	scraped[0].Title = "retitled"
+	ui.Add_and_update_follow(src.VideoPacket{Channel: "foo", Backend: "scrape", Vids: scraped})
+
+	for _, vid := range ui.Cache.As_slice() {
+		a.AssertEqual(t, src.VIDEO_LISTED, vid.Change)
+	}
+	a.AssertEqual(t, "vod", ui.Cache.Buffer[ui.Cache.Exists["0"]].Old_title)
+}
+
+func TestReruns(t *testing.T) {
+	ui := UIState{Settings: src.DEFAULT_SETTINGS}
+	ui.Load_config("live\nrerun\noffline")
+	now := time.Now()
+	ui.Add_and_update_follow(src.VideoPacket{Channel: "offline", Vids: []src.Video{{Channel: "offline", Url: "1", Title: "vod", Start_time: now.Add(-2 * time.Hour), Duration: time.Hour}}})
+	ui.Add_and_update_follow(src.VideoPacket{Channel: "rerun", Vids: []src.Video{{Channel: "rerun", Url: "2", Title: "old vod", Start_time: now.Add(-48 * time.Hour), Duration: time.Hour}}})
+	ui.Add_and_update_follow(src.VideoPacket{Live: true, Vids: []src.Video{{Channel: "live", Is_live: true, Start_time: now.Add(-3 * time.Hour), Duration: 3 * time.Hour}}})
+	ui.Add_and_update_follow(src.VideoPacket{Live: true, Vids: []src.Video{{Channel: "rerun", Title: "rerun", Is_live: true, Stream_type: src.STREAM_RERUN, Start_time: now.Add(-30 * time.Minute), Duration: 30 * time.Minute}}})
+
+	order := func(reruns string) []string {
+		ui.Settings.Reruns = reruns
+		ui.Build_follow_videos()
+		titles := []string{}
+		for _, vid := range ui.Follow_videos {
+			titles = append(titles, vid.Channel + ":" + vid.Title)
+		}
+		return titles
+	}
+	a.AssertEqual(t, []string{"rerun:rerun", "live:", "offline:vod"}, order(src.RERUNS_LIVE))
+	a.AssertEqual(t, []string{"live:", "rerun:rerun", "offline:vod"}, order(src.RERUNS_OFFLINE))
+	a.AssertEqual(t, []string{"live:", "offline:vod", "rerun:old vod"}, order(src.RERUNS_HIDE))
+}
+
+func TestLastLive(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	ui := UIState{Last_live: map[string]src.LastBroadcast{}}
+	ui.Load_config("foo\nbar")
+	long_ago := time.Now().Add(-74 * time.Hour)
+	// bar keeps no VODs, and twitch remembers when its last stream started
+	ui.Add_and_update_follow(src.VideoPacket{Live: true, Vids: []src.Video{{Channel: "bar", Last_live: src.LastBroadcast{Time: long_ago, Title: "old"}}}})
+	ui.Add_and_update_follow(src.VideoPacket{Live: true, Vids: []src.Video{{Channel: "foo", Title: "now", Is_live: true, Start_time: time.Now().Add(-time.Hour), Duration: time.Hour}}})
+	ui.Add_and_update_follow(src.VideoPacket{Live: true, Vids: []src.Video{{Channel: "foo"}}})
+
+	ui.Build_follow_videos()
+	lines := map[string]string{}
+	for _, vid := range ui.Follow_videos {
+		var builder strings.Builder
+		Print_formatted_line(&builder, "|", vid)
+		lines[vid.Channel] = builder.String()
+	}
+	a.AssertEqual(t, true, strings.Contains(lines["bar"], "last live 3 d ago · old"))
+	a.AssertEqual(t, true, strings.Contains(lines["foo"], "last live 0 min ago · now"))
+
+	// Saved when the stream ended
+	a.AssertEqual(t, "now", src.Load_last_live()["foo"].Title)
+}
+
+func TestChannelNextFilter(t *testing.T) {
+	ui := UIState{}
+	ui.Load_config("foo")
+	ui.Channel_filter = src.BROADCAST_ARCHIVE
+
+	// An unfiltered page that was in flight when `t` was pressed
+	ui.Add_and_update_follow(src.VideoPacket{Channel: "foo", Next: "all"})
+	_, ok := ui.Channel_next["foo"]
+	a.AssertEqual(t, false, ok)
+
+	ui.Add_and_update_follow(src.VideoPacket{Channel: "foo", Next: "archives", Broadcast_type: src.BROADCAST_ARCHIVE})
+	a.AssertEqual(t, "archives", ui.Channel_next["foo"])
+}
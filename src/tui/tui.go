@@ -7,10 +7,13 @@ import (
 	"slices"
 	"strings"
 	"os"
+	"time"
+	"unicode/utf8"
 
 	"io"
 	"os/exec"
 
+	"github.com/rivo/uniseg"
 	xterm "golang.org/x/term"
 
 	"github.com/yueleshia/streamsurf/src"
@@ -19,9 +22,8 @@ import (
 
 //run: go run ../../main.go
 
-func streamlink(ctx context.Context, output chan []byte, args ...string) error {
-	cmd := exec.CommandContext(ctx, "streamlink", args...)
-
+// Forwards the output of the player to the message area
+func run_player(cmd *exec.Cmd, output chan []byte) error {
 	var stdout, stderr io.ReadCloser
 	if pipe, err := cmd.StdoutPipe(); err != nil {
 		return err
@@ -74,6 +76,8 @@ func (self *UIState) Interactive() {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	defer self.Save_last_live()
+	defer self.Save_channel_ids()
 
 	//events := make(chan term.Event, 1000)
 
@@ -108,6 +112,9 @@ func (self *UIState) Interactive() {
 	refresh_queue := make(chan bool, 100)
 	self.Refresh_queue = make(chan src.VideoPacket, 100)
 	Refresh_channels(self.Refresh_queue, self.Channel_list...)
+	// Twitch only says who a channel raids before the raid goes through
+	raid_ticker := time.NewTicker(RAID_POLL_INTERVAL)
+	defer raid_ticker.Stop()
 
 	// Setup input loop
 	// We do not want tob lock the main loop, so that we can have async updates
@@ -142,12 +149,94 @@ func (self *UIState) Interactive() {
 		case <-ctx.Done(): break main_loop
 		case <-refresh_queue:
 
+		case packet := <-self.Search_queue:
+			// Drop answers to searches we have since replaced
+			if packet.Query != string(self.Search_input) {
+				break
+			}
+			self.Message.Reset()
+			if packet.Err != nil {
+				_, _ = self.Message.WriteString(packet.Err.Error() + "\n")
+			} else if len(packet.Results) == 0 {
+				_, _ = self.Message.WriteString("No channels found\n")
+			}
+			self.Search_results = packet.Results
+			self.Search_selection = 0
+
+		case packet := <-self.About_queue:
+			self.About.Put(packet.Channel, packet.Info, packet.Err)
+			if packet.Err != nil && self.Screen == ScreenAbout && packet.Channel == self.About_channel {
+				self.Message.Reset()
+				_, _ = self.Message.WriteString(packet.Err.Error() + "\n")
+			}
+
+		case packet := <-self.Schedule_queue:
+			self.Schedule = packet.Segments
+			self.Message.Reset()
+			for _, err := range packet.Errs {
+				_, _ = self.Message.WriteString(err.Error() + "\n")
+			}
+			if int(self.Schedule_selection) >= len(self.Schedule) {
+				self.Schedule_selection = 0
+			}
+
+		case x := <-self.Clip_queue:
+			self.Message.Reset()
+			if x.Done < 0 && x.Err != nil {
+				_, _ = self.Message.WriteString(fmt.Sprintf("Could not clip %s: %s\n", x.Path, x.Err))
+			} else if x.Done < 0 {
+				_, _ = self.Message.WriteString(fmt.Sprintf("Saved %s\n", x.Path))
+			} else {
+				_, _ = self.Message.WriteString(fmt.Sprintf("Clipping %s: %d/%d segments\n", x.Path, x.Done, x.Total))
+			}
+
+		case packet := <-self.Raid_queue:
+			if packet.Err != nil {
+				// Expected when twitch changes the query, see src.RAID_GRAPHQL_QUERY
+				src.L_DEBUG.Printf("Could not get the raid of %s: %s", packet.Raid.Channel, packet.Err)
+				break
+			}
+			self.Add_raid(packet.Raid)
+			// Ended before the next refresh, most likely in the raid
+			if pair, ok := self.Follow_latest[packet.Raid.Channel]; ok && pair.Live.Is_live && !packet.Raid.Is_live {
+				Refresh_channels(self.Refresh_queue, self.Entry(packet.Raid.Channel).String())
+			}
+
+		case <-raid_ticker.C:
+			if channels := self.Live_channels(); len(channels) > 0 && self.Settings.Raid_minutes > 0 {
+				Refresh_raids(self.Raid_queue, channels)
+			}
+
+		case packet := <-self.Status_queue:
+			if packet.Err != nil {
+				_, _ = self.Message.WriteString(fmt.Sprintf("Could not check %s: %s\n", packet.Status.Channel, packet.Err))
+				break
+			}
+			status := packet.Status
+			self.Channel_states[status.Channel] = status
+			if status.Id != "" && self.Channel_ids[status.Channel] != status.Id {
+				self.Channel_ids[status.Channel] = status.Id
+				self.Channel_ids_dirty = true
+			}
+			if status.State == src.CHANNEL_RENAMED {
+				_, _ = self.Message.WriteString(fmt.Sprintf("%s was renamed to %s, press w to follow the new name\n", status.Channel, status.Login))
+			} else if status.State != src.CHANNEL_ACTIVE {
+				_, _ = self.Message.WriteString(fmt.Sprintf("%s %s\n", status.Channel, status))
+			}
+			if self.Screen == ScreenFollow {
+				self.follow_swap()
+			}
+
 		case message := <-self.Log_queue:
 			fmt.Println("hello")
 			_, _ = self.Message.Write(message)
 
 		case packet := <-self.Refresh_queue:
 			if packet.Err != nil {
+				if self.Needs_status(packet) {
+					self.Refresh_status(packet.Channel)
+				}
+				delete(self.Channel_loading, packet.Channel)
 				_, _ = self.Message.WriteString(packet.Err.Error())
 				_ = self.Message.WriteByte('\n')
 			} else {
@@ -156,11 +245,15 @@ func (self *UIState) Interactive() {
 					_, _  = self.Message.WriteString("Refreshed\n")
 				}
 			}
-			slices.SortFunc(self.Follow_videos, src.Sort_videos_by_latest)
+			slices.SortFunc(self.Follow_videos, self.Settings.Sort_follow())
 
 			switch (self.Screen) {
 			case ScreenFollow: self.follow_swap()
 			case ScreenChannel: self.channel_swap(self.Channel)
+			case ScreenCategory: self.category_swap(self.Category)
+			case ScreenSearch:
+			case ScreenAbout:
+			case ScreenSchedule:
 			default: panic("DEV: Unsupport screen")
 			}
 
@@ -169,6 +262,10 @@ func (self *UIState) Interactive() {
 			switch (self.Screen) {
 			case ScreenFollow: is_break = self.follow_input(event, cancel)
 			case ScreenChannel: is_break = self.channel_input(event, cancel)
+			case ScreenCategory: is_break = self.category_input(event, cancel)
+			case ScreenSearch: is_break = self.search_input(event, cancel)
+			case ScreenAbout: is_break = self.about_input(event, cancel)
+			case ScreenSchedule: is_break = self.schedule_input(event, cancel)
 			default: panic("DEV: Unsupport screen")
 			}
 
@@ -186,47 +283,95 @@ func render(writer *bufio.Writer, ui UIState) {
 	switch ui.Screen {
 	case ScreenFollow: ui.follow_render(writer)
 	case ScreenChannel: ui.channel_render(writer)
+	case ScreenCategory: ui.category_render(writer)
+	case ScreenSearch: ui.search_render(writer)
+	case ScreenAbout: ui.about_render(writer)
+	case ScreenSchedule: ui.schedule_render(writer)
 	default: panic("DEV: Unsupport screen")
 	}
 	src.Must1(writer.Flush())
 }
 
-func render_video_list(writer *bufio.Writer, selection uint16, videos []src.Video) {
-	for i := uint16(0); int(i) < len(videos); i += 1 {
+func render_video_list(writer *bufio.Writer, rows int, selection uint16, videos []src.Video) {
+	// Scroll so that the selection is always on screen
+	offset := 0
+	if int(selection) >= rows {
+		offset = int(selection) - rows + 1
+	}
+	for i := 0; i < rows && offset + i < len(videos); i += 1 {
+		idx := offset + i
 		fmt.Fprintf(writer, "\x1B[%d;1H", i + 2)
-		if i == selection {
+		if idx == int(selection) {
 			fmt.Fprintf(writer, "\x1B[0;%s%s;%s%sm", term.Part_foreground, term.Part_white, term.Part_background, term.Part_black)
+		} else if videos[idx].Change != src.VIDEO_LISTED {
+			fmt.Fprint(writer, term.Dim)
 		}
-		Print_formatted_line(writer, " | ", videos[i])
-		if i == selection {
-			fmt.Fprintf(writer, term.Reset_attributes)
+		Print_formatted_line(writer, " | ", videos[idx])
+		if idx == int(selection) || videos[idx].Change != src.VIDEO_LISTED {
+			fmt.Fprint(writer, term.Reset_attributes)
 		}
 	}
 }
 
+// Leave room for the header, the key hints, and a few lines of messages
+func list_rows(height int, reserved int) int {
+	if height - reserved < 1 {
+		return 1
+	}
+	return height - reserved
+}
+
 func render_message(writer *bufio.Writer, message string) {
 	for part := range strings.SplitSeq(message, "\n") {
 		fmt.Fprintf(writer, "%s\r\n", part)
 	}
 }
 
+// `offset` is "" to start at the beginning (or live)
+func (self *UIState) play(vid src.Video, offset string) {
+	if err := src.Check_playable(vid); err != nil {
+		_, _ = self.Message.WriteString(err.Error() + "\n")
+		return
+	}
+
+	provider, err := self.Entry(vid.Channel).Get_provider()
+	if err != nil {
+		_, _ = self.Message.WriteString(err.Error() + "\n")
+		return
+	}
+	url, err := provider.Playback_url(vid)
+	if err != nil {
+		_, _ = self.Message.WriteString(err.Error() + "\n")
+		return
+	}
+
+	if offset == "" {
+		_, _ = self.Message.WriteString(fmt.Sprintf("Playing %s\n", url))
+	} else {
+		_, _ = self.Message.WriteString(fmt.Sprintf("Playing %s at %s\n", url, offset))
+	}
+	// Resolving the playlist is a few requests, do not block the UI on it
+	go func() {
+		// @TODO: Track if video is currently playing, and close it if we reopen. Maybe this is undesired behaviour?
+		cmd, err := src.Player_command(context.Background(), self.Player, provider, vid, offset)
+		if err == nil {
+			err = run_player(cmd, self.Log_queue)
+		}
+		if err != nil {
+			self.Log_queue <- []byte(err.Error() + "\n")
+		}
+	}()
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // Follow screen
 
 func (self *UIState) follow_swap() {
 	self.Screen = ScreenFollow
-
-	// @VOLATILE: Load_config seeds the keys
-	var idx uint = 0
-	for _, pair := range self.Follow_latest {
-		if pair.Live.Duration > 0 {
-			self.Follow_videos[idx] = pair.Live
-		} else {
-			self.Follow_videos[idx] = pair.Latest
-		}
-		idx += 1
+	self.Build_follow_videos()
+	if int(self.Follow_selection) >= len(self.Follow_videos) {
+		self.Follow_selection = uint16(max(len(self.Follow_videos) - 1, 0))
 	}
-	slices.SortFunc(self.Follow_videos, src.Sort_videos_by_latest)
 }
 
 func (self *UIState) follow_input(event term.Event, cancel context.CancelFunc) bool {
@@ -255,8 +400,49 @@ func (self *UIState) follow_input(event term.Event, cancel context.CancelFunc) b
 				self.Follow_selection -= 1
 			}
 		case 'l':
-			vid := self.Follow_videos[self.Follow_selection]
-			self.channel_swap(vid.Channel)
+			if len(self.Follow_videos) > 0 {
+				vid := self.Follow_videos[self.Follow_selection]
+				self.channel_swap(vid.Channel)
+			}
+		case 'g':
+			if len(self.Follow_videos) > 0 {
+				self.category_open(self.Follow_videos[self.Follow_selection])
+			}
+		case '/':
+			self.search_prompt()
+		case 'a':
+			if len(self.Follow_videos) > 0 {
+				self.about_open(self.Follow_videos[self.Follow_selection].Channel)
+			}
+		case 's':
+			self.schedule_open()
+		case 'w':
+			if changed, renamed, err := self.Rewrite_channels(); err != nil {
+				_, _ = self.Message.WriteString(err.Error() + "\n")
+			} else if changed == 0 {
+				_, _ = self.Message.WriteString("No renamed channels to write\n")
+			} else {
+				_, _ = self.Message.WriteString(fmt.Sprintf("Wrote %d changes to %s\n", changed, src.FOLLOW_FILE))
+				Refresh_channels(self.Refresh_queue, renamed...)
+				self.follow_swap()
+			}
+		case 'o':
+			if len(self.Follow_videos) > 0 {
+				channel := self.Follow_videos[self.Follow_selection].Channel
+				if raid, ok := self.Active_raid(channel); !ok {
+					_, _ = self.Message.WriteString(channel + " has not raided anyone\n")
+				} else if vid, ok := self.Live_video(raid.Target); ok {
+					self.play(vid, "")
+				} else {
+					// We only know the login, which is all a live stream needs
+					self.play(src.Video{
+						Title:   "Raid from " + channel,
+						Channel: raid.Target,
+						Is_live: true,
+						Url:     "https://www.twitch.tv/" + raid.Target,
+					}, "")
+				}
+			}
 
 		default:
 			self.Message.WriteString(fmt.Sprintf("%d %+v\n", event.Ty, event))
@@ -272,14 +458,10 @@ func (self UIState) follow_render(writer *bufio.Writer) {
 	fmt.Fprint(writer, "Follow\n")
 	height_left -= 1
 
-	to_render := self.Follow_videos
-	if len(to_render) < height_left - 2 {
-		to_render = to_render[:len(to_render)]
-	}
-	render_video_list(writer, self.Follow_selection, to_render)
+	render_video_list(writer, list_rows(height_left, 6), self.Follow_selection, self.Follow_videos)
 
-	fmt.Fprintf(writer, "\r\n (q)uit (r)efresh (hjkl) navigate")
-	fmt.Fprintf(writer, "\r\n")
+	fmt.Fprintf(writer, "\r\n (q)uit (r)efresh (g)ame category (/) search (a)bout (s)chedule (o)pen raid (w)rite renames (hjkl) navigate")
+	fmt.Fprintf(writer, "\r\nBackends: %s", Format_health(src.Health_report()))
 	fmt.Fprintf(writer, "\r\nui_selection: %d\r\n", self.Follow_selection)
 	fmt.Fprintf(writer, "\r\n")
 	render_message(writer, self.Message.String())
@@ -294,17 +476,29 @@ func (self *UIState) channel_swap(channel string) {
 	self.Channel_command = self.Channel_command[:0]
 
 	self.Channel_videos.Clear()
-	if pair, ok := self.Follow_latest[channel]; ok && pair.Live.Duration > 0 {
-		self.Channel_videos.Push(pair.Live)
+	if live, ok := self.Live_video(channel); ok {
+		self.Channel_videos.Push(live)
 	}
+	entry := self.Entry(channel)
 	for _, vid := range self.Cache.As_slice() {
-		if vid.Channel == self.Channel {
+		if vid.Channel == self.Channel && !Is_filtered_out(entry, self.Channel_filter, vid) {
 			self.Channel_videos.Push(vid)
 		}
 	}
 	slices.SortFunc(self.Channel_videos.As_slice(), src.Sort_videos_by_latest)
 }
 
+// Request the next page of VODs once the selection reaches the bottom
+func (self *UIState) channel_load_more() {
+	next, ok := self.Channel_next[self.Channel]
+	if !ok || next == "" || self.Channel_loading[self.Channel] {
+		return
+	}
+	self.Channel_loading[self.Channel] = true
+	_, _ = self.Message.WriteString("Loading more VODs...\n")
+	Refresh_page(self.Refresh_queue, self.Entry(self.Channel), next, self.Channel_filter)
+}
+
 func (self *UIState) channel_input(event term.Event, cancel context.CancelFunc) bool {
 	self.Message.Reset()
 	switch event.Ty {
@@ -320,8 +514,43 @@ func (self *UIState) channel_input(event term.Event, cancel context.CancelFunc)
 			return true
 
 		case 'r':
-			Refresh_channels(self.Refresh_queue, self.Channel)
-			
+			Refresh_channels(self.Refresh_queue, self.Entry(self.Channel).String())
+
+		case 'g':
+			if len(self.Channel_videos.As_slice()) > 0 {
+				self.category_open(self.Channel_videos.Buffer[self.Channel_selection])
+			}
+		case 'a':
+			self.about_open(self.Channel)
+
+		case 'm':
+			vid := self.Channel_videos.Buffer[self.Channel_selection]
+			if !vid.Is_live && len(self.Channel_command) > 0 {
+				self.Clip_from = string(self.Channel_command)
+				self.Channel_command = self.Channel_command[:0]
+				_, _ = self.Message.WriteString("Clip starts at " + self.Clip_from + ", type where it ends then press x\n")
+			}
+		case 'x':
+			vid := self.Channel_videos.Buffer[self.Channel_selection]
+			if self.Clip_from == "" || len(self.Channel_command) == 0 {
+				_, _ = self.Message.WriteString("Type where the clip starts and press m, then where it ends and press x\n")
+			} else if output, err := Start_clip(self.Clip_queue, self.Entry(vid.Channel), vid, self.Player.Quality, self.Clip_from, string(self.Channel_command)); err != nil {
+				_, _ = self.Message.WriteString(err.Error() + "\n")
+			} else {
+				_, _ = self.Message.WriteString("Clipping to " + output + "\n")
+				self.Clip_from = ""
+				self.Channel_command = self.Channel_command[:0]
+			}
+
+		case 't':
+			self.Channel_filter = Next_filter(self.Channel_filter)
+			self.Channel_selection = 0
+			self.channel_swap(self.Channel)
+			// The pages we have were for the previous filter, start over
+			delete(self.Channel_next, self.Channel)
+			self.Channel_loading[self.Channel] = true
+			Refresh_page(self.Refresh_queue, self.Entry(self.Channel), "", self.Channel_filter)
+
 		case 'h':
 			for i, vid := range self.Follow_videos {
 				if vid.Channel == self.Channel {
@@ -332,29 +561,31 @@ func (self *UIState) channel_input(event term.Event, cancel context.CancelFunc)
 			self.Screen = ScreenFollow
 
 		case 'j':
-			if int(self.Channel_selection) + 1 < len(self.Channel_videos.Buffer) {
+			count := len(self.Channel_videos.As_slice())
+			if int(self.Channel_selection) + 1 < count {
 				self.Channel_command = self.Channel_command[:0] // Clear time selection
 				self.Channel_selection += 1
 			}
+			if int(self.Channel_selection) + 1 >= count {
+				self.channel_load_more()
+			}
 		case 'k':
 			if self.Channel_selection > 0 {
 				self.Channel_command = self.Channel_command[:0] // Clear time selection
 				self.Channel_selection -= 1
 			}
 		case 'l':
-			if len(self.Channel_videos.Buffer) > 0 {
-				ctx, cancel := context.WithCancel(context.Background())
+			if len(self.Channel_videos.As_slice()) > 0 {
 				vid := self.Channel_videos.Buffer[self.Channel_selection]
-
-				if vid.Is_live || len(self.Channel_command) == 0 {
-					_, _ = self.Message.WriteString(fmt.Sprintf("Playing %s\n", vid.Url))
-					go streamlink(ctx, self.Log_queue, vid.Url)
-				} else {
-					_, _ = self.Message.WriteString(fmt.Sprintf("Playing %s at %s\n", vid.Url, self.Channel_command))
-					go streamlink(ctx, self.Log_queue, vid.Url, "--hls-start-offset", string(self.Channel_command))
+				offset := ""
+				if !vid.Is_live && len(self.Channel_command) > 0 {
+					offset = string(self.Channel_command)
+					if skipped := src.Start_offset(self.Player, vid, offset); skipped != offset {
+						_, _ = self.Message.WriteString(fmt.Sprintf("Skipping muted audio from %s\n", offset))
+						offset = skipped
+					}
 				}
-				// @TODO: Track if video is currently playing, and close it if we reopen. Maybe this is undesired behaviour?
-				_ = cancel
+				self.play(vid, offset)
 			}
 		case '0','1','2','3','4','5','6','7','8','9', ':':
 			vid := self.Channel_videos.Buffer[self.Channel_selection]
@@ -381,14 +612,10 @@ func (self *UIState) channel_input(event term.Event, cancel context.CancelFunc)
 
 func (self UIState) channel_render(writer *bufio.Writer) {
 	height_left := self.Height
-	fmt.Fprintf(writer, "Channel %s\n", self.Channel)
+	fmt.Fprintf(writer, "Channel %s  Type: %s\n", self.Channel, src.Broadcast_type_name(self.Channel_filter))
 	height_left -= 1
 
-	to_render := self.Channel_videos.As_slice()
-	if len(to_render) < height_left - 2 {
-		to_render = to_render[:len(to_render)]
-	}
-	render_video_list(writer, self.Channel_selection, to_render)
+	render_video_list(writer, list_rows(height_left, 13), self.Channel_selection, self.Channel_videos.As_slice())
 
 	// Display play time
 	vid := self.Channel_videos.Buffer[self.Channel_selection]
@@ -396,18 +623,406 @@ func (self UIState) channel_render(writer *bufio.Writer) {
 	if !vid.Is_live && len(self.Channel_command) > 0 {
 		fmt.Fprintf(writer, "\r\n Length (hh:mm:ss): %s\r\n", string(self.Channel_command))
 	}
+	if self.Clip_from != "" {
+		fmt.Fprintf(writer, "\r\n Clip from %s to %s\r\n", self.Clip_from, string(self.Channel_command))
+	}
 
-	fmt.Fprintf(writer, "\r\n (q)uit (r)efresh (hjkl) navigate")
+	fmt.Fprintf(writer, "\r\n (q)uit (r)efresh (t)ype filter (g)ame category (a)bout (m)ark clip start, clip (x) (hjkl) navigate")
 	fmt.Fprintf(writer, "\r\n")
 	fmt.Fprintf(writer, "\r\n%s", vid.Url)
 	fmt.Fprintf(writer, "\r\n%s", vid.Title)
-	fmt.Fprintf(writer, "\r\nChapters: ")
-	for i, chapter := range vid.Chapters {
-		if i != 0 {
-			fmt.Fprintf(writer, "%s", " | ")
+	fmt.Fprintf(writer, "\r\nChapters: %s", Format_timeline(vid.Chapters))
+	for _, line := range Format_details(vid) {
+		fmt.Fprintf(writer, "\r\n%s", line)
+	}
+	fmt.Fprintf(writer, "\r\n")
+	render_message(writer, self.Message.String())
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Category screen
+
+// Live streams first, then VODs
+func (self *UIState) category_swap(key string) {
+	self.Screen = ScreenCategory
+	self.Category = key
+	self.Category_videos = append(self.Category_videos[:0], self.Category_live[key]...)
+	self.Category_videos = append(self.Category_videos, self.Category_vods[key]...)
+	if int(self.Category_selection) >= len(self.Category_videos) {
+		self.Category_selection = 0
+	}
+}
+
+// Opens the category of what `video` is playing
+func (self *UIState) category_open(video src.Video) {
+	name := video.Game
+	if name == "" && len(video.Chapters) > 0 {
+		name = video.Chapters[len(video.Chapters) - 1].Name
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		_, _ = self.Message.WriteString("No category for this video\n")
+		return
+	}
+	self.Category_selection = 0
+	self.Category_is_name = true
+	self.category_swap(name)
+	_, _ = self.Message.WriteString("Loading " + name + "...\n")
+	Refresh_category(self.Refresh_queue, name, true)
+}
+
+func (self *UIState) category_input(event term.Event, cancel context.CancelFunc) bool {
+	self.Message.Reset()
+	switch event.Ty {
+	case term.TyCodepoint:
+		switch event.X {
+		case 'c':
+			if event.Mod_ctrl {
+				cancel()
+				return true
+			}
+		case 'q':
+			cancel()
+			return true
+
+		case 'r':
+			Refresh_category(self.Refresh_queue, self.Category, self.Category_is_name)
+
+		case 'h':
+			self.follow_swap()
+
+		case 'j':
+			if int(self.Category_selection) + 1 < len(self.Category_videos) {
+				self.Category_selection += 1
+			}
+		case 'k':
+			if self.Category_selection > 0 {
+				self.Category_selection -= 1
+			}
+		case 'l':
+			if len(self.Category_videos) > 0 {
+				vid := self.Category_videos[self.Category_selection]
+				self.Channel_selection = 0
+				self.channel_swap(vid.Channel)
+			}
+
+		default:
 		}
-		fmt.Fprintf(writer, "%s", chapter.Name)
+	default:
+		self.Message.WriteString(fmt.Sprintf("%d %+v\n", event.Ty, event))
 	}
+	return false
+}
+
+func (self UIState) category_render(writer *bufio.Writer) {
+	height_left := self.Height
+	name := self.Category
+	if videos := self.Category_live[self.Category]; len(videos) > 0 {
+		name = videos[0].Game
+	}
+	fmt.Fprintf(writer, "Category %s  Live: %d\n", name, len(self.Category_live[self.Category]))
+	height_left -= 1
+
+	render_video_list(writer, list_rows(height_left, 10), self.Category_selection, self.Category_videos)
+
+	fmt.Fprintf(writer, "\r\n (q)uit (r)efresh (hjkl) navigate")
+	fmt.Fprintf(writer, "\r\n")
+	if len(self.Category_videos) > 0 {
+		vid := self.Category_videos[self.Category_selection]
+		fmt.Fprintf(writer, "\r\n%s", vid.Url)
+		fmt.Fprintf(writer, "\r\n%s", vid.Title)
+		for _, line := range Format_details(vid) {
+			fmt.Fprintf(writer, "\r\n%s", line)
+		}
+	}
+	fmt.Fprintf(writer, "\r\n")
+	render_message(writer, self.Message.String())
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Search screen
+
+func (self *UIState) search_prompt() {
+	self.Screen = ScreenSearch
+	self.Search_input = self.Search_input[:0]
+	self.Search_is_typing = true
+}
+
+func (self *UIState) search_input(event term.Event, cancel context.CancelFunc) bool {
+	self.Message.Reset()
+
+	// Typing a query, every key is text until enter
+	if self.Search_is_typing {
+		if event.Ty != term.TyCodepoint {
+			// e.g. Escape
+			self.Search_is_typing = false
+			return false
+		}
+		switch {
+		case event.Mod_ctrl && event.X == 'c':
+			cancel()
+			return true
+		case event.X == '\n':
+			query := string(self.Search_input)
+			self.Search_is_typing = false
+			if query == "" {
+				break
+			}
+			_, _ = self.Message.WriteString("Searching...\n")
+			go func() {
+				results, err := src.Graph_search(query)
+				self.Search_queue <- SearchPacket{Query: query, Results: results, Err: err}
+			}()
+		case event.X == 127:
+			if length := len(self.Search_input); length > 0 {
+				self.Search_input = self.Search_input[:length - 1]
+			}
+		case !event.Mod_ctrl && event.X >= ' ':
+			self.Search_input = utf8.AppendRune(self.Search_input, event.X)
+		}
+		return false
+	}
+
+	switch event.Ty {
+	case term.TyCodepoint:
+		switch event.X {
+		case 'c':
+			if event.Mod_ctrl {
+				cancel()
+				return true
+			}
+		case 'q':
+			cancel()
+			return true
+
+		case '/':
+			self.search_prompt()
+		case 'h':
+			self.follow_swap()
+
+		case 'j':
+			if int(self.Search_selection) + 1 < len(self.Search_results) {
+				self.Search_selection += 1
+			}
+		case 'k':
+			if self.Search_selection > 0 {
+				self.Search_selection -= 1
+			}
+		case 'l':
+			if len(self.Search_results) > 0 {
+				channel := self.Search_results[self.Search_selection].Channel.Login
+				self.Channel_selection = 0
+				self.channel_swap(channel)
+				Refresh_channels(self.Refresh_queue, self.Entry(channel).String())
+			}
+		case 'a':
+			if len(self.Search_results) > 0 {
+				self.about_open(self.Search_results[self.Search_selection].Channel.Login)
+			}
+		case 'f':
+			if len(self.Search_results) > 0 {
+				channel := self.Search_results[self.Search_selection].Channel.Login
+				entry := self.Entry(channel)
+				if err := self.Follow(entry); err != nil {
+					_, _ = self.Message.WriteString(err.Error() + "\n")
+				} else {
+					_, _ = self.Message.WriteString("Following " + channel + "\n")
+					Refresh_channels(self.Refresh_queue, entry.String())
+				}
+			}
+
+		default:
+		}
+	default:
+		self.Message.WriteString(fmt.Sprintf("%d %+v\n", event.Ty, event))
+	}
+	return false
+}
+
+func (self UIState) search_render(writer *bufio.Writer) {
+	height_left := self.Height
+	if self.Search_is_typing {
+		fmt.Fprintf(writer, "Search: %s_\n", self.Search_input)
+	} else {
+		fmt.Fprintf(writer, "Search: %s\n", self.Search_input)
+	}
+	height_left -= 1
+
+	rows := list_rows(height_left, 6)
+	offset := 0
+	if int(self.Search_selection) >= rows {
+		offset = int(self.Search_selection) - rows + 1
+	}
+	for i := 0; i < rows && offset + i < len(self.Search_results); i += 1 {
+		idx := offset + i
+		result := self.Search_results[idx]
+		fmt.Fprintf(writer, "\x1B[%d;1H", i + 2)
+		if idx == int(self.Search_selection) {
+			fmt.Fprintf(writer, "\x1B[0;%s%s;%s%sm", term.Part_foreground, term.Part_white, term.Part_background, term.Part_black)
+		}
+		Print_search_line(writer, " | ", result, self.Is_following(result.Channel.Login))
+		if idx == int(self.Search_selection) {
+			fmt.Fprint(writer, term.Reset_attributes)
+		}
+	}
+
+	if self.Search_is_typing {
+		fmt.Fprintf(writer, "\r\n (enter) search (esc) stop typing")
+	} else {
+		fmt.Fprintf(writer, "\r\n (q)uit (/) search again (f)ollow (a)bout (hjkl) navigate")
+	}
+	fmt.Fprintf(writer, "\r\n")
+	render_message(writer, self.Message.String())
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// About screen
+
+func (self *UIState) about_open(channel string) {
+	self.Screen = ScreenAbout
+	self.About_channel = channel
+	self.About_scroll = 0
+	if _, ok := self.About.Get(channel); !ok {
+		_, _ = self.Message.WriteString("Loading about " + channel + "...\n")
+		Refresh_about(self.About_queue, self.Entry(channel))
+	}
+}
+
+func (self *UIState) about_input(event term.Event, cancel context.CancelFunc) bool {
+	self.Message.Reset()
+	switch event.Ty {
+	case term.TyCodepoint:
+		switch event.X {
+		case 'c':
+			if event.Mod_ctrl {
+				cancel()
+				return true
+			}
+		case 'q':
+			cancel()
+			return true
+
+		case 'r':
+			Refresh_about(self.About_queue, self.Entry(self.About_channel))
+		case 'h':
+			self.follow_swap()
+		case 'l':
+			self.Channel_selection = 0
+			self.channel_swap(self.About_channel)
+		case 'j':
+			self.About_scroll += 1
+		case 'k':
+			if self.About_scroll > 0 {
+				self.About_scroll -= 1
+			}
+
+		default:
+		}
+	default:
+		self.Message.WriteString(fmt.Sprintf("%d %+v\n", event.Ty, event))
+	}
+	return false
+}
+
+func (self UIState) about_render(writer *bufio.Writer) {
+	height_left := self.Height
+	fmt.Fprintf(writer, "About %s\r\n", self.About_channel)
+	height_left -= 1
+
+	rows := list_rows(height_left, 6)
+	if entry, ok := self.About.Entries[self.About_channel]; ok && entry.Err == nil {
+		lines := Format_about(entry.Info)
+		start := min(self.About_scroll, max(len(lines) - rows, 0))
+		for i := start; i < len(lines) && i < start + rows; i += 1 {
+			line := lines[i]
+			if uniseg.StringWidth(line) > self.Width {
+				line, _ = break_unicode_before(self.Width, line)
+			}
+			fmt.Fprintf(writer, "%s\r\n", line)
+		}
+	}
+
+	fmt.Fprintf(writer, "\r\n (q)uit (r)efresh (h) back (l) VODs (jk) scroll")
+	fmt.Fprintf(writer, "\r\n")
+	render_message(writer, self.Message.String())
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Schedule screen
+
+func (self *UIState) schedule_open() {
+	self.Screen = ScreenSchedule
+	if self.Schedule == nil {
+		_, _ = self.Message.WriteString("Loading schedules...\n")
+		Refresh_schedule(self.Schedule_queue, self.Schedule_channels())
+	}
+}
+
+func (self *UIState) schedule_input(event term.Event, cancel context.CancelFunc) bool {
+	self.Message.Reset()
+	switch event.Ty {
+	case term.TyCodepoint:
+		switch event.X {
+		case 'c':
+			if event.Mod_ctrl {
+				cancel()
+				return true
+			}
+		case 'q':
+			cancel()
+			return true
+
+		case 'r':
+			_, _ = self.Message.WriteString("Loading schedules...\n")
+			Refresh_schedule(self.Schedule_queue, self.Schedule_channels())
+		case 'h':
+			self.follow_swap()
+		case 'j':
+			if int(self.Schedule_selection) + 1 < len(self.Schedule) {
+				self.Schedule_selection += 1
+			}
+		case 'k':
+			if self.Schedule_selection > 0 {
+				self.Schedule_selection -= 1
+			}
+		case 'l':
+			if len(self.Schedule) > 0 {
+				self.Channel_selection = 0
+				self.channel_swap(self.Schedule[self.Schedule_selection].Channel)
+			}
+
+		default:
+		}
+	default:
+		self.Message.WriteString(fmt.Sprintf("%d %+v\n", event.Ty, event))
+	}
+	return false
+}
+
+func (self UIState) schedule_render(writer *bufio.Writer) {
+	height_left := self.Height
+	fmt.Fprint(writer, "Schedule\n")
+	height_left -= 1
+
+	now := time.Now()
+	rows := list_rows(height_left, 6)
+	offset := 0
+	if int(self.Schedule_selection) >= rows {
+		offset = int(self.Schedule_selection) - rows + 1
+	}
+	for i := 0; i < rows && offset + i < len(self.Schedule); i += 1 {
+		idx := offset + i
+		fmt.Fprintf(writer, "\x1B[%d;1H", i + 2)
+		if idx == int(self.Schedule_selection) {
+			fmt.Fprintf(writer, "\x1B[0;%s%s;%s%sm", term.Part_foreground, term.Part_white, term.Part_background, term.Part_black)
+		}
+		Print_schedule_line(writer, " | ", self.Schedule[idx], now)
+		if idx == int(self.Schedule_selection) {
+			fmt.Fprint(writer, term.Reset_attributes)
+		}
+	}
+
+	fmt.Fprintf(writer, "\r\n (q)uit (r)efresh (h) back (l) VODs (jk) navigate")
 	fmt.Fprintf(writer, "\r\n")
 	render_message(writer, self.Message.String())
 }
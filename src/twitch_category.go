@@ -0,0 +1,225 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Categories are what twitch calls games in its API, e.g. "Just Chatting"
+// Only GraphQL knows about them, there is nothing to scrape
+
+// The slug is the end of e.g. https://www.twitch.tv/directory/category/just-chatting
+// There is no rule to get it from the name, e.g. "Pokémon" is "pokemon" and
+// "Baldur's Gate 3" is "baldurs-gate-3", so everything that starts from a name
+// looks it up by name instead.
+var CATEGORY_GRAPHQL_QUERY = strings.ReplaceAll(`query category($slug: String!, $limit: Int) {
+    game(slug: $slug) `, "\n", "") + CATEGORY_GRAPHQL_FIELDS
+
+var CATEGORY_BY_NAME_GRAPHQL_QUERY = strings.ReplaceAll(`query category($name: String!, $limit: Int) {
+    game(name: $name) `, "\n", "") + CATEGORY_GRAPHQL_FIELDS
+
+var CATEGORY_GRAPHQL_FIELDS = strings.ReplaceAll(`{
+        id
+        name
+        displayName
+        streams(first: $limit) {
+            edges {
+                node {
+                    id
+                    title
+                    createdAt
+                    viewersCount
+                    previewImageURL(width: 320, height: 180)
+                    freeformTags {
+                        name
+                    }
+                    broadcaster {
+                        login
+                        displayName
+                        profileImageURL(width: 50)
+                        broadcastSettings {
+                            language
+                        }
+                    }
+                }
+            }
+        }
+        videos(first: $limit, sort: TIME) {
+            edges {
+                node {
+                    id
+                    title
+                    description
+                    viewCount
+                    previewThumbnailURL(width: 320, height: 180)
+                    publishedAt
+                    lengthSeconds
+                    broadcastType
+                    owner {
+                        login
+                        displayName
+                        profileImageURL(width: 50)
+                    }
+                }
+            }
+        }
+    }
+}`, "\n", "")
+
+type CategoryData struct {
+	Game *struct {
+		Id           string `json:"id"`
+		Name         string `json:"name"`
+		Display_name string `json:"displayName"`
+		Streams struct {
+			Edges []struct {
+				Node struct {
+					Id            string `json:"id"`
+					Title         string `json:"title"`
+					Created_at    string `json:"createdAt"`
+					Viewers_count int    `json:"viewersCount"`
+					Preview_URL   string `json:"previewImageURL"`
+					Freeform_tags []struct {
+						Name string `json:"name"`
+					} `json:"freeformTags"`
+					Broadcaster *struct {
+						Login        string `json:"login"`
+						Display_name string `json:"displayName"`
+						Profile_URL  string `json:"profileImageURL"`
+						Broadcast_settings struct {
+							Language string `json:"language"`
+						} `json:"broadcastSettings"`
+					} `json:"broadcaster"`
+				} `json:"node"`
+			} `json:"edges"`
+		} `json:"streams"`
+		Videos struct {
+			Edges []struct {
+				Node struct {
+					Id             string `json:"id"`
+					Title          string `json:"title"`
+					Description    string `json:"description"`
+					View_count     int    `json:"viewCount"`
+					Thumbnail_URL  string `json:"previewThumbnailURL"`
+					Published_at   string `json:"publishedAt"`
+					Length_seconds int    `json:"lengthSeconds"`
+					Broadcast_type string `json:"broadcastType"`
+					Owner *struct {
+						Login        string `json:"login"`
+						Display_name string `json:"displayName"`
+						Profile_URL  string `json:"profileImageURL"`
+					} `json:"owner"`
+				} `json:"node"`
+			} `json:"edges"`
+		} `json:"videos"`
+	} `json:"game"`
+}
+
+type Category struct {
+	Name string
+	Key  string // The slug or name it was looked up by
+	Live []Video // Most viewers first
+	Vods []Video // Latest first
+}
+
+// For "category:<slug>" lines of the channel list
+func Graph_category(slug string) (Category, error) {
+	return graph_category(slug, GqlOperation{
+		Operation_name: "category",
+		Variables: map[string]any{"slug": slug, "limit": PAGE_SIZE},
+		Query: CATEGORY_GRAPHQL_QUERY,
+	}, fmt.Sprintf("graph-category-%s", slug))
+}
+
+// e.g. Video.Game or what the user typed, case does not matter
+func Graph_category_by_name(name string) (Category, error) {
+	return graph_category(name, GqlOperation{
+		Operation_name: "category",
+		Variables: map[string]any{"name": name, "limit": PAGE_SIZE},
+		Query: CATEGORY_BY_NAME_GRAPHQL_QUERY,
+	}, fmt.Sprintf("graph-category-name-%s", name))
+}
+
+func graph_category(key string, operation GqlOperation, cache_id string) (Category, error) {
+	var data CategoryData
+	if _, err := Gql(context.TODO(), operation, &data, cache_id); err != nil {
+		return Category{Key: key}, err
+	}
+	return parse_category_query(key, data)
+}
+
+func parse_category_query(key string, data CategoryData) (Category, error) {
+	if data.Game == nil {
+		return Category{Key: key}, ErrMissing{message: "Category " + key + " does not exist"}
+	}
+	game := data.Game
+	category := Category{Name: game.Display_name, Key: key}
+	if category.Name == "" {
+		category.Name = game.Name
+	}
+
+	for _, edge := range game.Streams.Edges {
+		x := edge.Node
+		// Banned channels can still show up without a broadcaster
+		if x.Broadcaster == nil {
+			continue
+		}
+		start, err := time.Parse(time.RFC3339, x.Created_at)
+		if err != nil {
+			return category, err
+		}
+		tags := make([]string, len(x.Freeform_tags))
+		for i, tag := range x.Freeform_tags {
+			tags[i] = tag.Name
+		}
+
+		duration := time.Now().Sub(start)
+		category.Live = append(category.Live, Video{
+			Title: x.Title,
+			Channel: x.Broadcaster.Login,
+			Thumbnail_URL: []string{x.Preview_URL},
+			Start_time: start,
+			Duration: duration,
+			Is_live: true,
+			Url: "https://www.twitch.tv/" + x.Broadcaster.Login,
+			Chapters: []Chapter{{Name: category.Name, Duration: duration}},
+			Display_name: x.Broadcaster.Display_name,
+			Avatar_URL: x.Broadcaster.Profile_URL,
+			Game: category.Name,
+			Language: x.Broadcaster.Broadcast_settings.Language,
+			Tags: tags,
+			Viewers: x.Viewers_count,
+			Peak_viewers: x.Viewers_count,
+		})
+	}
+
+	for _, edge := range game.Videos.Edges {
+		x := edge.Node
+		if x.Owner == nil {
+			continue
+		}
+		start, err := time.Parse(time.RFC3339, x.Published_at)
+		if err != nil {
+			return category, err
+		}
+		duration := time.Duration(x.Length_seconds) * time.Second
+		category.Vods = append(category.Vods, Video{
+			Title: x.Title,
+			Channel: x.Owner.Login,
+			Thumbnail_URL: []string{x.Thumbnail_URL},
+			Start_time: start,
+			Duration: duration,
+			Url: "https://www.twitch.tv/videos/" + x.Id,
+			Chapters: []Chapter{{Name: category.Name, Duration: duration}},
+			Broadcast_type: x.Broadcast_type,
+			Display_name: x.Owner.Display_name,
+			Avatar_URL: x.Owner.Profile_URL,
+			Game: category.Name,
+			Description: x.Description,
+			View_count: x.View_count,
+		})
+	}
+	return category, nil
+}
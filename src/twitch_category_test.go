@@ -0,0 +1,40 @@
+package src
+
+import (
+	"testing"
+
+	a "github.com/yueleshia/streamsurf/src/testify"
+)
+
+//run: go test -v
+
+func TestCategoryParse(t *testing.T) {
+	response := `{"game": {
+		"id": "1", "name": "Chess", "displayName": "Chess",
+		"streams": {"edges": [
+			{"node": {"id": "s1", "title": "Blitz", "createdAt": "2025-01-01T00:00:00Z", "viewersCount": 900,
+				"broadcaster": {"login": "foo", "displayName": "Foo", "broadcastSettings": {"language": "en"}}}},
+			{"node": {"id": "s2", "title": "Banned", "createdAt": "2025-01-01T00:00:00Z", "viewersCount": 10, "broadcaster": null}}
+		]},
+		"videos": {"edges": [
+			{"node": {"id": "42", "title": "Old", "publishedAt": "2024-01-01T00:00:00Z", "lengthSeconds": 60,
+				"broadcastType": "ARCHIVE", "owner": {"login": "bar", "displayName": "Bar"}}}
+		]}
+	}}`
+	var data CategoryData
+	a.AssertEqual(t, nil, Decode_json("test.category", []byte(response), &data))
+
+	category, err := parse_category_query("chess", data)
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, "Chess", category.Name)
+	a.AssertEqual(t, 1, len(category.Live))
+	a.AssertEqual(t, "foo", category.Live[0].Channel)
+	a.AssertEqual(t, 900, category.Live[0].Viewers)
+	a.AssertEqual(t, true, category.Live[0].Is_live)
+	a.AssertEqual(t, 1, len(category.Vods))
+	a.AssertEqual(t, "https://www.twitch.tv/videos/42", category.Vods[0].Url)
+
+	_, err = parse_category_query("nope", CategoryData{})
+	_, is_missing := err.(ErrMissing)
+	a.AssertEqual(t, true, is_missing)
+}
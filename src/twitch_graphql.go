@@ -2,9 +2,8 @@ package src
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
+	"slices"
 	"strings"
 	"time"
 )
@@ -19,6 +18,8 @@ import (
 var VODS_GRAPHQL_QUERY = strings.ReplaceAll(`query videos($channelOwnerLogin: String!, $limit: Int, $cursor: Cursor, $broadcastType: BroadcastType, $videoSort: VideoSort, $options: VideoConnectionOptionsInput) {
     user(login: $channelOwnerLogin) {
         id
+        displayName
+        profileImageURL(width: 50)
 
         videos(first: $limit, after: $cursor, type: $broadcastType, sort: $videoSort, options: $options) {
             edges {
@@ -27,9 +28,24 @@ var VODS_GRAPHQL_QUERY = strings.ReplaceAll(`query videos($channelOwnerLogin: St
                     __typename
                     id
                     title
+                    description
+                    viewCount
                     previewThumbnailURL(width: 320, height: 180)
                     publishedAt
                     lengthSeconds
+                    broadcastType
+                    status
+                    resourceRestriction {
+                        type
+                    }
+                    muteInfo {
+                        mutedSegmentConnection {
+                            nodes {
+                                offset
+                                duration
+                            }
+                        }
+                    }
                     game {
                         name
                     }
@@ -39,11 +55,13 @@ var VODS_GRAPHQL_QUERY = strings.ReplaceAll(`query videos($channelOwnerLogin: St
                         login
                         profileImageURL(width: 50)
                     }
-                    moments(first: 0, after: null, sort: ASC, types: GAME_CHANGE, momentRequestType: VIDEO_CHAPTER_MARKERS) {
+                    moments(first: 100, after: null, sort: ASC, types: GAME_CHANGE, momentRequestType: VIDEO_CHAPTER_MARKERS) {
                         edges {
+                            cursor
                             node {
                                 description
                                 positionMilliseconds
+                                durationMilliseconds
                             }
                         }
                         pageInfo {
@@ -59,133 +77,266 @@ var VODS_GRAPHQL_QUERY = strings.ReplaceAll(`query videos($channelOwnerLogin: St
 
         stream {
             createdAt
+            type
+            viewersCount
+            freeformTags {
+                name
+            }
+        }
+        broadcastSettings {
+            game {
+                name
+            }
+            title
+            language
+        }
+        lastBroadcast {
+            startedAt
+            title
+        }
+    }
+}`, "\n", "")
+
+type VideoNode struct {
+	Typename       string `json:"__typename"`
+	Id             string `json:"id"`
+	Title          string `json:"title"`
+	Description    string `json:"description"`
+	View_count     int    `json:"viewCount"`
+	Thumbnail_URL  string `json:"previewThumbnailURL"`
+	Published_at   string `json:"publishedAt"`
+	Length_seconds int    `json:"lengthSeconds"`
+	Broadcast_type string `json:"broadcastType"`
+	Status         string `json:"status"`
+	Resource_restriction *struct {
+		Type string `json:"type"`
+	} `json:"resourceRestriction"`
+	Mute_info *struct {
+		Muted_segment_connection *struct {
+			Nodes []struct {
+				Offset   int `json:"offset"`   // Seconds
+				Duration int `json:"duration"` // Seconds
+			} `json:"nodes"`
+		} `json:"mutedSegmentConnection"`
+	} `json:"muteInfo"`
+	Game struct {
+		Name string `json:"name"`
+	} `json:"game"`
+	Owner struct {
+		Id            string `json:"id"`
+		Display_name  string `json:"displayName"`
+		Login         string `json:"login"`
+		Profile_URL   string `json:"profileImageURL"`
+	} `json:"owner"`
+	Moments MomentConnection `json:"moments"`
+}
+
+func (self VideoNode) Muted_ranges() []MutedRange {
+	if self.Mute_info == nil || self.Mute_info.Muted_segment_connection == nil {
+		return nil
+	}
+	var ranges []MutedRange
+	for _, x := range self.Mute_info.Muted_segment_connection.Nodes {
+		ranges = append(ranges, MutedRange{
+			Offset:   time.Duration(x.Offset) * time.Second,
+			Duration: time.Duration(x.Duration) * time.Second,
+		})
+	}
+	slices.SortFunc(ranges, func(a, b MutedRange) int { return int(a.Offset - b.Offset) })
+	return ranges
+}
+
+type VideoEdge struct {
+	Cursor string    `json:"cursor"`
+	Node   VideoNode `json:"node"`
+}
+type VideosData struct {
+	User struct {
+		LiveUser
+		Videos struct {
+			Edges []VideoEdge `json:"edges"`
+			Page_info struct {
+				Has_next_page bool `json:"hasNextPage"`
+			} `json:"pageInfo"`
+		} `json:"videos"`
+	} `json:"user"`
+}
+
+// The fields of VODS_GRAPHQL_QUERY related to live status, which is all that
+// LIVE_GRAPHQL_QUERY asks for
+type LiveUser struct {
+	Id           string `json:"id"`
+	Display_name string `json:"displayName"`
+	Profile_URL  string `json:"profileImageURL"`
+
+	Stream *struct {
+		Created_at    string `json:"createdAt"`
+		Type          string `json:"type"`
+		Viewers_count int    `json:"viewersCount"`
+		Freeform_tags []struct {
+			Name string `json:"name"`
+		} `json:"freeformTags"`
+	} `json:"stream"`
+	Broadcast_settings struct {
+		Game struct {
+			Name string `json:"name"`
+		} `json:"game"`
+		Title    string `json:"title"`
+		Language string `json:"language"`
+	} `json:"broadcastSettings"`
+	Last_broadcast *struct {
+		Started_at *string `json:"startedAt"` // Null if the channel never streamed
+		Title      *string `json:"title"`
+	} `json:"lastBroadcast"`
+}
+
+type LiveData struct {
+	User LiveUser `json:"user"`
+}
+
+// Only the live status of VODS_GRAPHQL_QUERY, without the VODs and their chapters
+var LIVE_GRAPHQL_QUERY = strings.ReplaceAll(`query live($login: String!) {
+    user(login: $login) {
+        id
+        displayName
+        profileImageURL(width: 50)
+
+        stream {
+            createdAt
+            type
+            viewersCount
+            freeformTags {
+                name
+            }
         }
         broadcastSettings {
             game {
                 name
             }
             title
+            language
+        }
+        lastBroadcast {
+            startedAt
+            title
         }
     }
 }`, "\n", "")
+
+func Graph_live(channel string) (Video, error) {
+	var data LiveData
+	if _, err := Gql(context.TODO(), GqlOperation{
+		Operation_name: "live",
+		Variables: map[string]any{"login": channel},
+		Query: LIVE_GRAPHQL_QUERY,
+	}, &data, "graph-live-" + channel); err != nil {
+		return Video{Channel: channel}, err
+	}
+	return parse_live_user(channel, data.User)
+}
+
+type VideosVariables struct {
+	Broadcast_type      *string `json:"broadcastType"`
+	Channel_owner_login string  `json:"channelOwnerLogin"`
+	Cursor              *string `json:"cursor"`
+	Limit               int     `json:"limit"`
+	Video_sort          string  `json:"videoSort"`
+}
+
 func Graph_vods(channel string) (VideoPacket, Video) {
+	return Graph_vods_page(channel, "", "")
+}
+
+// Pass the VideoPacket.Next of the previous page as `cursor` to get the page
+// after it. An empty `cursor` requests the first page.
+// `broadcast_type` is one of BROADCAST_*, or "" for all of them.
+func Graph_vods_page(channel string, cursor string, broadcast_type string) (VideoPacket, Video) {
+	packets, lives := graph_videos([]string{channel}, []string{cursor}, broadcast_type, fmt.Sprintf("graph-%s-videos-%s-%s", channel, broadcast_type, cursor))
+	return packets[0], lives[0]
+}
+
+// GQL accepts a JSON array of operations and answers with an array in the same
+// order, so we can refresh several channels with a single request.
+// The i-th packet and live video belong to channels[i].
+func Graph_vods_batch(channels []string) ([]VideoPacket, []Video) {
+	if len(channels) == 0 {
+		return nil, nil
+	}
+	cursors := make([]string, len(channels))
+	return graph_videos(channels, cursors, "", batch_cache_id("graph-batch", channels))
+}
+
+func videos_operation(channel string, cursor string, broadcast_type string) GqlOperation {
 	// url format https://www.twitch.tv/qtcinderella/videos?filter=all&sort=time (query params may or may not be there)
-	variables := strings.Join([]string{
-		`{`,
-		`"broadcastType":null,`,
-		`"channelOwnerLogin":"` + channel  + `",`,
-		`"cursor":null,`,
-		`"limit":` + fmt.Sprintf("%d", PAGE_SIZE) + `,`,
-		`"videoSort":"TIME"`,
-		`}`,
-	}, "")
-	query := strings.Join([]string{
-		"[{",
-		`"operationName": "videos",`,
-		`"variables":` + variables + `,`,
-		`"query":"` + VODS_GRAPHQL_QUERY + `"`,
-		"}]",
-	}, "")
-	Assert(json.Valid([]byte(query)))
+	return GqlOperation{
+		Operation_name: "videos",
+		Variables: VideosVariables{
+			Broadcast_type:      null_if_empty(broadcast_type),
+			Channel_owner_login: channel,
+			Cursor:              null_if_empty(cursor),
+			Limit:               PAGE_SIZE,
+			Video_sort:          "TIME",
+		},
+		Query: VODS_GRAPHQL_QUERY,
+	}
+}
 
+func graph_videos(channels []string, cursors []string, broadcast_type string, cache_id string) ([]VideoPacket, []Video) {
+	Assert(len(channels) == len(cursors))
+	packets := make([]VideoPacket, len(channels))
+	lives := make([]Video, len(channels))
 
-	videos := [PAGE_SIZE]Video{}
-	var request io.ReadCloser
-	{
-		x, err := Request(context.TODO(), "POST", map[string]string{
-			//"Authorization": void 0,
-			"Accept": "*/*",
-			"Accept-Language": "en-US",
-			"Content-Type": "text/plain; charset=UTF-8",
-			"Client-Id": CLIENT_ID,
-			//"Device-ID": void 0,
-		}, strings.NewReader(query), "https://gql.twitch.tv/gql#origin=twilight", fmt.Sprintf("graph-%s-videos", channel))
+	operations := make([]GqlOperation, len(channels))
+	for i, channel := range channels {
+		operations[i] = videos_operation(channel, cursors[i], broadcast_type)
+	}
+
+	responses, err := Gql_batch(context.TODO(), operations, cache_id)
+	var pending []chapter_page
+	for i, channel := range channels {
 		if err != nil {
-			return VideoPacket{videos[:0], false, err}, Video{}
+			packets[i] = VideoPacket{Err: err, Channel: channel, Cursor: cursors[i]}
+			continue
+		}
+
+		var data VideosData
+		if err := responses[i].Decode(&data); err != nil {
+			packets[i] = VideoPacket{Err: err, Channel: channel, Cursor: cursors[i]}
+			continue
+		}
+		var more []chapter_page
+		packets[i], lives[i], more = parse_videos_query(channel, cursors[i], data)
+		packets[i].Broadcast_type = broadcast_type
+		packets[i].Complete = packets[i].Err == nil
+		for _, x := range more {
+			x.packet = i
+			pending = append(pending, x)
 		}
-		request = x
 	}
+	fetch_more_chapters(packets, pending, cache_id)
+	return packets, lives
+}
 
+// Videos with more chapters than fit in VODS_GRAPHQL_QUERY are returned as
+// chapter_page, see fetch_more_chapters
+func parse_videos_query(channel string, cursor string, data VideosData) (VideoPacket, Video, []chapter_page) {
+	videos := [PAGE_SIZE]Video{}
+	next := ""
+	var pending []chapter_page
 	ret, live_vid, err := func() ([]Video, Video, error) {
-		live_video := Video {
-			Channel: channel,
+		// A null user, e.g. renamed or suspended, see Graph_channel_status
+		if data.User.Id == "" {
+			return videos[:0], Video{Channel: channel}, ErrMissing{message: "Channel " + channel + " does not exist"}
 		}
-		type VideoNode struct {
-			Typename       string `json:"__typename"`
-			Id             string `json:"id"`
-			Title          string `json:"title"`
-			Thumbnail_URL  string `json:"previewThumbnailURL"`
-			Published_at   string `json:"publishedAt"`
-			Length_seconds int    `json:"lengthSeconds"`
-			Game struct {
-				Name string `json:"name"`
-			} `json:"game"`
-			Owner struct {
-				Id            string `json:"id"`
-				Display_name  string `json:"displayName"`
-				Login         string `json:"login"`
-				Profile_URL   string `json:"profileImageURL"`
-			} `json:"owner"`
-			Moments struct {
-				Edges []struct {
-					Node struct {
-						Description           string        `json:"description"`
-						Position_milliseconds time.Duration `json:"positionMilliseconds"`
-					} `json:"node"`
-				} `json:"edges"`
-				Page_info struct {
-					Has_next_page bool `json:"hasNextPage"`
-				} `json:"pageInfo"`
-			} `json:"moments"`
-
-		}
-		type VideoEdge struct {
-			Cursor string    `json:"cursor"`
-			Node   VideoNode `json:"node"`
-		}
-		type Query struct {
-			Data struct {
-				User struct {
-					Id string `json:"id"`
-					Videos struct {
-						Edges []VideoEdge `json:"edges"`
-						Page_info struct {
-							Has_next_page bool `json:"hasNextPage"`
-						} `json:"pageInfo"`
-					} `json:"videos"`
-
-					// Related to live status
-					Stream *struct {
-						Created_at string `json:"createdAt"`
-					} `json:"stream"`
-					Broadcast_settings struct {
-						Game struct {
-							Name string `json:"name"`
-						} `json:"game"`
-						Title string `json:"title"`
-					} `json:"broadcastSettings"`
-				} `json:"user"`
-			} `json:"data"`
-			Extensions struct {
-				Duration_milliseconds int    `json:"durationMilliseconds"`
-				Operation_name        string `json:"operationName"`
-				Request_id            string `json:"requestID"`
-
-			} `json:"extensions"`
-		}
-
-		var unmarshalled []Query
-		dec := json.NewDecoder(request)
-		dec.DisallowUnknownFields()
-		if err := dec.Decode(&unmarshalled); err != nil {
-			return videos[:0], Video{}, err
-		}
-
-		video_edges := unmarshalled[0].Data.User.Videos.Edges
+
+		video_edges := data.User.Videos.Edges
 		min_length := PAGE_SIZE
 		if len(video_edges) < min_length {
 			min_length = len(video_edges)
 		}
+		if data.User.Videos.Page_info.Has_next_page && min_length > 0 {
+			next = video_edges[min_length - 1].Cursor
+		}
 		idx := 0
 		for i := min_length - 1; i >= 0; i -= 1 {
 			x := video_edges[i].Node
@@ -197,17 +348,18 @@ func Graph_vods(channel string) (VideoPacket, Video) {
 				start = x
 			}
 
+			duration := time.Duration(x.Length_seconds) * time.Second
 			var chapters []Chapter
+			var moment_cursor string
 			if len(x.Moments.Edges) == 0 {
-				chapters = []Chapter{Chapter{x.Game.Name, 0}}
+				chapters = []Chapter{{Name: x.Game.Name}}
 			} else {
-				chapters = make([]Chapter, len(x.Moments.Edges))
-				for j, y := range x.Moments.Edges {
-					chapters[j] = Chapter {
-						Name:     y.Node.Description,
-						Position: y.Node.Position_milliseconds * time.Millisecond,
-					}
-				}
+				chapters, moment_cursor = x.Moments.As_chapters()
+			}
+			if moment_cursor != "" {
+				pending = append(pending, chapter_page{video: idx, video_id: x.Id, cursor: moment_cursor})
+			} else {
+				Fill_chapter_durations(chapters, duration)
 			}
 
 			videos[idx] = Video {
@@ -215,40 +367,383 @@ func Graph_vods(channel string) (VideoPacket, Video) {
 				Channel: channel,
 				Thumbnail_URL: []string{x.Thumbnail_URL},
 				Start_time: start,
-				Duration: time.Duration(x.Length_seconds) * time.Second,
+				Duration: duration,
 				Is_live: false,
 				Url: "https://www.twitch.tv/videos/" + x.Id,
 				Chapters: chapters,
+				Broadcast_type: x.Broadcast_type,
+				Display_name: x.Owner.Display_name,
+				Avatar_URL: x.Owner.Profile_URL,
+				Game: x.Game.Name,
+				Language: data.User.Broadcast_settings.Language,
+				Description: x.Description,
+				View_count: x.View_count,
+				Muted: x.Muted_ranges(),
+				Status: x.Status,
+			}
+			if x.Resource_restriction != nil {
+				videos[idx].Restriction = x.Resource_restriction.Type
 			}
 			idx += 1
 		}
 
-		// Is live
-		if unmarshalled[0].Data.User.Stream != nil {
-			user := unmarshalled[0].Data.User
-			var start time.Time
-			if x, err := time.Parse(time.RFC3339, user.Stream.Created_at); err != nil {
-				return videos[:0], live_video, err
-			} else {
-				start = x
-			}
-
-			live_video = Video{
-				Title: user.Broadcast_settings.Title,
-				Channel: channel,
-				Thumbnail_URL: []string{},
-				Start_time: start,
-				Duration: time.Now().Sub(start),
-				Is_live: true,
-				Url: "https://www.twitch.tv/" + channel,
-				Chapters: []Chapter{Chapter{user.Broadcast_settings.Game.Name, 0}},
-			}
+		live_video, err := parse_live_user(channel, data.User.LiveUser)
+		if err != nil {
+			return videos[:0], live_video, err
 		}
-
 		return videos[:idx], live_video, nil
 	}()
 	if err != nil {
-		return VideoPacket{videos[:0], false, err}, live_vid
+		return VideoPacket{Vids: videos[:0], Err: err, Channel: channel, Cursor: cursor}, live_vid, nil
+	}
+	return VideoPacket{Vids: ret, Channel: channel, Cursor: cursor, Next: next, User_id: data.User.Id}, live_vid, pending
+}
+
+// Last_live is only set when the channel is offline
+func parse_live_user(channel string, user LiveUser) (Video, error) {
+	live_video := Video {
+		Channel: channel,
+	}
+	if user.Id == "" {
+		return live_video, ErrMissing{message: "Channel " + channel + " does not exist"}
+	}
+	if last := user.Last_broadcast; last != nil && last.Started_at != nil {
+		if x, err := time.Parse(time.RFC3339, *last.Started_at); err == nil {
+			live_video.Last_live.Time = x
+		}
+		if last.Title != nil {
+			live_video.Last_live.Title = *last.Title
+		}
+	}
+
+	if user.Stream != nil {
+		var start time.Time
+		if x, err := time.Parse(time.RFC3339, user.Stream.Created_at); err != nil {
+			return live_video, err
+		} else {
+			start = x
+		}
+
+		tags := make([]string, len(user.Stream.Freeform_tags))
+		for i, tag := range user.Stream.Freeform_tags {
+			tags[i] = tag.Name
+		}
+
+		live_duration := time.Now().Sub(start)
+		live_video = Video{
+			Title: user.Broadcast_settings.Title,
+			Channel: channel,
+			Thumbnail_URL: []string{},
+			Start_time: start,
+			Duration: live_duration,
+			Is_live: true,
+			Url: "https://www.twitch.tv/" + channel,
+			Chapters: []Chapter{{Name: user.Broadcast_settings.Game.Name, Duration: live_duration}},
+			Display_name: user.Display_name,
+			Avatar_URL: user.Profile_URL,
+			Game: user.Broadcast_settings.Game.Name,
+			Language: user.Broadcast_settings.Language,
+			Tags: tags,
+			Stream_type: Parse_stream_type(user.Stream.Type),
+			Viewers: user.Stream.Viewers_count,
+			Peak_viewers: user.Stream.Viewers_count,
+		}
+	}
+	return live_video, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Chapters
+
+// GAME_CHANGE moments are what the web player shows as chapters
+type MomentConnection struct {
+	Edges []struct {
+		Cursor string `json:"cursor"`
+		Node struct {
+			Description           string        `json:"description"`
+			Position_milliseconds time.Duration `json:"positionMilliseconds"`
+			Duration_milliseconds time.Duration `json:"durationMilliseconds"`
+		} `json:"node"`
+	} `json:"edges"`
+	Page_info struct {
+		Has_next_page bool `json:"hasNextPage"`
+	} `json:"pageInfo"`
+}
+
+// Also returns the cursor for the next page, "" if this is the last page
+func (self MomentConnection) As_chapters() ([]Chapter, string) {
+	chapters := make([]Chapter, len(self.Edges))
+	for i, x := range self.Edges {
+		chapters[i] = Chapter {
+			Name:     x.Node.Description,
+			Position: x.Node.Position_milliseconds * time.Millisecond,
+			Duration: x.Node.Duration_milliseconds * time.Millisecond,
+		}
+	}
+	if self.Page_info.Has_next_page && len(self.Edges) > 0 {
+		return chapters, self.Edges[len(self.Edges) - 1].Cursor
+	}
+	return chapters, ""
+}
+
+// Twitch does not always send a duration, in which case a chapter lasts until
+// the next one starts, and the last chapter lasts until the end of the video
+func Fill_chapter_durations(chapters []Chapter, video_duration time.Duration) {
+	for i := range chapters {
+		if chapters[i].Duration > 0 {
+			continue
+		}
+		close := video_duration
+		if i + 1 < len(chapters) {
+			close = chapters[i + 1].Position
+		}
+		if close > chapters[i].Position {
+			chapters[i].Duration = close - chapters[i].Position
+		}
+	}
+}
+
+var MOMENTS_GRAPHQL_QUERY = strings.ReplaceAll(`query moments($videoID: ID!, $cursor: Cursor) {
+    video(id: $videoID) {
+        moments(first: 100, after: $cursor, sort: ASC, types: GAME_CHANGE, momentRequestType: VIDEO_CHAPTER_MARKERS) {
+            edges {
+                cursor
+                node {
+                    description
+                    positionMilliseconds
+                    durationMilliseconds
+                }
+            }
+            pageInfo {
+                hasNextPage
+            }
+        }
+    }
+}`, "\n", "")
+
+type MomentsData struct {
+	Video struct {
+		Moments MomentConnection `json:"moments"`
+	} `json:"video"`
+}
+
+// The next page of chapters of packets[packet].Vids[video]
+type chapter_page struct {
+	packet   int
+	video    int
+	video_id string
+	cursor   string
+}
+
+func moments_operation(video_id string, cursor string) GqlOperation {
+	type Variables struct {
+		Video_id string  `json:"videoID"`
+		Cursor   *string `json:"cursor"`
+	}
+	return GqlOperation{
+		Operation_name: "moments",
+		Variables: Variables{video_id, null_if_empty(cursor)},
+		Query: MOMENTS_GRAPHQL_QUERY,
+	}
+}
+
+// Only needed for videos with more chapters than fit in VODS_GRAPHQL_QUERY.
+// Every video that has more goes into the same batch, so this is one request
+// per page of chapters rather than one per video.
+func fetch_more_chapters(packets []VideoPacket, pending []chapter_page, cache_id string) {
+	for round := 0; len(pending) > 0; round += 1 {
+		operations := make([]GqlOperation, len(pending))
+		for i, x := range pending {
+			operations[i] = moments_operation(x.video_id, x.cursor)
+		}
+		responses, err := Gql_batch(context.TODO(), operations, fmt.Sprintf("%s-moments-%d", cache_id, round))
+		if err != nil {
+			L_ERROR.Printf("Could not get all chapters: %s", err)
+		}
+
+		var next []chapter_page
+		for i, x := range pending {
+			video := &packets[x.packet].Vids[x.video]
+			x.cursor = ""
+			if err == nil {
+				var data MomentsData
+				if err := responses[i].Decode(&data); err != nil {
+					L_ERROR.Printf("Could not get all chapters for %s: %s", x.video_id, err)
+				} else {
+					var more []Chapter
+					more, x.cursor = data.Video.Moments.As_chapters()
+					video.Chapters = append(video.Chapters, more...)
+				}
+			}
+			if x.cursor != "" {
+				next = append(next, x)
+			} else {
+				Fill_chapter_durations(video.Chapters, video.Duration)
+			}
+		}
+		pending = next
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Provider
+
+type TwitchGraph struct{}
+
+func (TwitchGraph) Vods(channel string, cursor string, broadcast_type string) VideoPacket {
+	vods, _ := Graph_vods_page(channel, cursor, broadcast_type)
+	vods.Stamp_backend("graphql")
+	return vods
+}
+
+func (TwitchGraph) Live_status(channel string) VideoPacket {
+	live, err := Graph_live(channel)
+	packet := VideoPacket{Vids: []Video{live}, Live: true, Err: err, Channel: channel}
+	packet.Stamp_backend("graphql")
+	return packet
+}
+
+func (TwitchGraph) Refresh(channels []string) []VideoPacket {
+	vods, lives := Graph_vods_batch(channels)
+	ret := make([]VideoPacket, 0, len(channels) * 2)
+	for i, channel := range channels {
+		ret = append(ret, vods[i], VideoPacket{Vids: []Video{lives[i]}, Live: true, Channel: channel})
+	}
+	for i := range ret {
+		ret[i].Stamp_backend("graphql")
+	}
+	return ret
+}
+
+func (TwitchGraph) Channel_info(channel string) (ChannelInfo, error) {
+	return Graph_channel_info(channel)
+}
+
+func (TwitchGraph) Playback_url(video Video) (string, error) {
+	return video.Url, nil
+}
+
+func (TwitchGraph) Playback_variants(video Video) ([]Variant, error) {
+	return Resolve_variants(video)
+}
+
+// Roughly what the about page of twitch.tv asks for
+var CHANNEL_GRAPHQL_QUERY = strings.ReplaceAll(`query channel($login: String!) {
+    user(login: $login) {
+        id
+        login
+        displayName
+        description
+        profileImageURL(width: 150)
+        followers {
+            totalCount
+        }
+        roles {
+            isPartner
+            isAffiliate
+        }
+        primaryTeam {
+            name
+            displayName
+        }
+        channel {
+            socialMedias {
+                name
+                title
+                url
+            }
+        }
+        panels {
+            type
+            ... on DefaultPanel {
+                title
+                description
+                linkURL
+                imageURL
+            }
+        }
+    }
+}`, "\n", "")
+
+type ChannelData struct {
+	User *struct {
+		Id           string `json:"id"`
+		Login        string `json:"login"`
+		Display_name string `json:"displayName"`
+		Description  string `json:"description"`
+		Profile_URL  string `json:"profileImageURL"`
+		Followers struct {
+			Total_count int `json:"totalCount"`
+		} `json:"followers"`
+		Roles struct {
+			Is_partner   bool `json:"isPartner"`
+			Is_affiliate bool `json:"isAffiliate"`
+		} `json:"roles"`
+		Primary_team *struct {
+			Name         string `json:"name"`
+			Display_name string `json:"displayName"`
+		} `json:"primaryTeam"`
+		Channel struct {
+			Social_medias []struct {
+				Name  string `json:"name"`
+				Title string `json:"title"`
+				Url   string `json:"url"`
+			} `json:"socialMedias"`
+		} `json:"channel"`
+		Panels []struct {
+			Type        string `json:"type"`
+			Title       string `json:"title"`
+			Description string `json:"description"`
+			Link_URL    string `json:"linkURL"`
+			Image_URL   string `json:"imageURL"`
+		} `json:"panels"`
+	} `json:"user"`
+}
+
+func Graph_channel_info(channel string) (ChannelInfo, error) {
+	var data ChannelData
+	if _, err := Gql(context.TODO(), GqlOperation{
+		Operation_name: "channel",
+		Variables: map[string]string{"login": channel},
+		Query: CHANNEL_GRAPHQL_QUERY,
+	}, &data, fmt.Sprintf("graph-%s-channel", channel)); err != nil {
+		return ChannelInfo{}, err
+	}
+	return parse_channel_query(channel, data)
+}
+
+func parse_channel_query(channel string, data ChannelData) (ChannelInfo, error) {
+	if data.User == nil {
+		return ChannelInfo{}, ErrMissing{message: "Channel " + channel + " does not exist"}
+	}
+	user := data.User
+	info := ChannelInfo{
+		Id:           user.Id,
+		Login:        user.Login,
+		Display_name: user.Display_name,
+		Description:  user.Description,
+		Avatar_URL:   user.Profile_URL,
+		Followers:    user.Followers.Total_count,
+		Is_partner:   user.Roles.Is_partner,
+		Is_affiliate: user.Roles.Is_affiliate,
+	}
+	if user.Primary_team != nil {
+		info.Team = user.Primary_team.Display_name
+	}
+	for _, x := range user.Channel.Social_medias {
+		info.Socials = append(info.Socials, SocialLink{Name: x.Name, Title: x.Title, Url: x.Url})
+	}
+	for _, x := range user.Panels {
+		// Extension panels are iframes, there is nothing for us to show
+		if x.Type != "DEFAULT" {
+			continue
+		}
+		info.Panels = append(info.Panels, Panel{
+			Title:       x.Title,
+			Description: x.Description,
+			Link_URL:    x.Link_URL,
+			Image_URL:   x.Image_URL,
+		})
 	}
-	return VideoPacket{ret, false, request.Close()}, live_vid
+	return info, nil
 }
@@ -1,7 +1,11 @@
 package src
 
 import (
+	"strings"
 	"testing"
+	"time"
+
+	a "github.com/yueleshia/streamsurf/src/testify"
 )
 
 //run: go test -v
@@ -10,12 +14,151 @@ const DEV_TWITCH = true
 
 func TestAdd(t *testing.T) {
 	if DEV_TWITCH {
-		result := Scrape_vods("limealicious")
+		result := Scrape_vods("limealicious", "")
 		if result.Err != nil {
 			t.Logf("ERROR: %s", result.Err)
 		}
-		t.Logf("%+v", result.Val)
+		t.Logf("%+v", result.Vids)
+	}
+}
+
+func TestChapterDurations(t *testing.T) {
+	chapters := []Chapter{
+		{Name: "Just Chatting", Position: 0},
+		{Name: "Elden Ring", Position: 40 * time.Minute, Duration: 30 * time.Minute},
+		{Name: "Just Chatting", Position: 70 * time.Minute},
+	}
+	Fill_chapter_durations(chapters, 3 * time.Hour)
+	a.AssertEqual(t, 40 * time.Minute, chapters[0].Duration)
+	a.AssertEqual(t, 30 * time.Minute, chapters[1].Duration)
+	a.AssertEqual(t, 110 * time.Minute, chapters[2].Duration)
+}
+
+func TestClientId(t *testing.T) {
+	page := `<html><head>
+		<script type="application/ld+json">{}</script>
+		<script>window.__twilightSettings = {"a":1};clientId="kimne78kx3ncx6brgo4mv6wki5h1ko",commitHash="x"</script>
+	</head><body></body></html>`
+	client_id, err := read_twitch_client_id(strings.NewReader(page))
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, "kimne78kx3ncx6brgo4mv6wki5h1ko", client_id)
+}
+
+func TestVideosMetadata(t *testing.T) {
+	response := `{"user": {
+		"id": "1", "displayName": "Foo", "profileImageURL": "avatar.png",
+		"videos": {"edges": [{"cursor": "c1", "node": {
+			"id": "42", "title": "VOD", "description": "Line one\nline two", "viewCount": 1234,
+			"publishedAt": "2025-01-01T00:00:00Z", "lengthSeconds": 3600, "broadcastType": "ARCHIVE",
+			"status": "RECORDED", "resourceRestriction": {"type": "SUB_ONLY_LIVE"},
+			"muteInfo": {"mutedSegmentConnection": {"nodes": [{"offset": 600, "duration": 60}, {"offset": 180, "duration": 30}]}},
+			"game": {"name": "Chess"}, "owner": {"displayName": "Foo", "login": "foo", "profileImageURL": "avatar.png"},
+			"moments": {"edges": []}
+		}}], "pageInfo": {"hasNextPage": false}},
+		"stream": {"createdAt": "2025-01-02T00:00:00Z", "type": "rerun", "viewersCount": 56, "freeformTags": [{"name": "English"}, {"name": "Chill"}]},
+		"broadcastSettings": {"game": {"name": "Just Chatting"}, "title": "Live", "language": "en"},
+		"lastBroadcast": {"startedAt": "2025-01-02T00:00:00Z", "title": "Live"}
+	}}`
+	var data VideosData
+	a.AssertEqual(t, nil, Decode_json("test.videos", []byte(response), &data))
+
+	packet, live, _ := parse_videos_query("foo", "", data)
+	a.AssertEqual(t, nil, packet.Err)
+	a.AssertEqual(t, 1, len(packet.Vids))
+	vod := packet.Vids[0]
+	a.AssertEqual(t, "Foo", vod.Display_name)
+	a.AssertEqual(t, "Chess", vod.Game)
+	a.AssertEqual(t, "en", vod.Language)
+	a.AssertEqual(t, "Line one\nline two", vod.Description)
+	a.AssertEqual(t, 1234, vod.View_count)
+	a.AssertEqual(t, []MutedRange{
+		{Offset: 3 * time.Minute, Duration: 30 * time.Second},
+		{Offset: 10 * time.Minute, Duration: time.Minute},
+	}, vod.Muted)
+	a.AssertEqual(t, RESTRICTION_SUB_ONLY, vod.Restriction)
+	a.AssertEqual(t, `Cannot play "VOD", the VOD is restricted to subscribers only`, Check_playable(vod).Error())
+	a.AssertEqual(t, nil, Check_playable(Video{Status: VIDEO_STATUS_RECORDING}))
+	a.AssertEqual(t, `Cannot play "", the VOD is not available (transcoding)`, Check_playable(Video{Status: "TRANSCODING"}).Error())
+
+	a.AssertEqual(t, true, live.Is_live)
+	a.AssertEqual(t, "Just Chatting", live.Game)
+	a.AssertEqual(t, "avatar.png", live.Avatar_URL)
+	a.AssertEqual(t, []string{"English", "Chill"}, live.Tags)
+	a.AssertEqual(t, 56, live.Viewers)
+	a.AssertEqual(t, 56, live.Peak_viewers)
+	a.AssertEqual(t, STREAM_RERUN, live.Stream_type)
+	a.AssertEqual(t, "", Parse_stream_type("live"))
+}
+
+func TestLiveOnly(t *testing.T) {
+	response := `{"user": {
+		"id": "1", "displayName": "Foo", "profileImageURL": "avatar.png",
+		"stream": null,
+		"broadcastSettings": {"game": {"name": "Chess"}, "title": "Offline", "language": "en"},
+		"lastBroadcast": {"startedAt": "2025-01-02T00:00:00Z", "title": "Yesterday"}
+	}}`
+	var data LiveData
+	a.AssertEqual(t, nil, Decode_json("test.live", []byte(response), &data))
+	live, err := parse_live_user("foo", data.User)
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, false, live.Is_live)
+	a.AssertEqual(t, "Yesterday", live.Last_live.Title)
+
+	// LiveData is what the live query answers, no more and no less
+	for _, drift := range Drift_report() {
+		a.AssertEqual(t, true, drift.Name != "test.live")
 	}
+
+	_, err = parse_live_user("foo", LiveUser{})
+	_, is_missing := err.(ErrMissing)
+	a.AssertEqual(t, true, is_missing)
 }
 
+func TestChannelAbout(t *testing.T) {
+	response := `{"user": {
+		"id": "1", "login": "foo", "displayName": "Foo", "description": "Hi",
+		"followers": {"totalCount": 4321},
+		"roles": {"isPartner": false, "isAffiliate": true},
+		"primaryTeam": {"name": "bar", "displayName": "Bar"},
+		"channel": {"socialMedias": [{"name": "youtube", "title": "YouTube", "url": "https://youtube.com/foo"}]},
+		"panels": [
+			{"type": "DEFAULT", "title": "Schedule", "description": "Mondays", "linkURL": "https://example.com"},
+			{"type": "EXTENSION"}
+		]
+	}}`
+	var data ChannelData
+	a.AssertEqual(t, nil, Decode_json("test.channel", []byte(response), &data))
 
+	info, err := parse_channel_query("foo", data)
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, 4321, info.Followers)
+	a.AssertEqual(t, true, info.Is_affiliate)
+	a.AssertEqual(t, "Bar", info.Team)
+	a.AssertEqual(t, []SocialLink{{Name: "youtube", Title: "YouTube", Url: "https://youtube.com/foo"}}, info.Socials)
+	a.AssertEqual(t, []Panel{{Title: "Schedule", Description: "Mondays", Link_URL: "https://example.com"}}, info.Panels)
+
+	_, err = parse_channel_query("nope", ChannelData{})
+	_, is_missing := err.(ErrMissing)
+	a.AssertEqual(t, true, is_missing)
+}
+
+func TestMoreChapters(t *testing.T) {
+	response := `{"user": {
+		"id": "1",
+		"videos": {"edges": [
+			{"cursor": "c1", "node": {"id": "42", "title": "Many", "publishedAt": "2025-01-02T00:00:00Z", "lengthSeconds": 3600,
+				"moments": {"edges": [{"cursor": "m1", "node": {"description": "Chess", "positionMilliseconds": 0}}], "pageInfo": {"hasNextPage": true}}}},
+			{"cursor": "c2", "node": {"id": "41", "title": "Few", "publishedAt": "2025-01-01T00:00:00Z", "lengthSeconds": 60,
+				"moments": {"edges": [{"cursor": "m2", "node": {"description": "Chess", "positionMilliseconds": 0}}], "pageInfo": {"hasNextPage": false}}}}
+		], "pageInfo": {"hasNextPage": false}}
+	}}`
+	var data VideosData
+	a.AssertEqual(t, nil, Decode_json("test.videos", []byte(response), &data))
+
+	// Fetched later in one batch, so the durations are not filled in yet
+	packet, _, pending := parse_videos_query("foo", "", data)
+	a.AssertEqual(t, nil, packet.Err)
+	a.AssertEqual(t, []chapter_page{{video: 1, video_id: "42", cursor: "m1"}}, pending)
+	a.AssertEqual(t, time.Duration(0), packet.Vids[1].Chapters[0].Duration)
+	a.AssertEqual(t, time.Minute, packet.Vids[0].Chapters[0].Duration)
+}
@@ -0,0 +1,130 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Public follows of a user and members of a team, for `streamsurf import`
+
+// Following thousands of channels is rare, but stop somewhere
+const IMPORT_MAX_PAGES = 50
+const IMPORT_PAGE_SIZE = 100
+
+var FOLLOWS_GRAPHQL_QUERY = strings.ReplaceAll(`query follows($login: String!, $first: Int, $cursor: Cursor) {
+    user(login: $login) {
+        id
+        follows(first: $first, after: $cursor) {
+            edges {
+                cursor
+                node {
+                    login
+                }
+            }
+            pageInfo {
+                hasNextPage
+            }
+        }
+    }
+}`, "\n", "")
+
+var TEAM_GRAPHQL_QUERY = strings.ReplaceAll(`query team($name: String!, $first: Int, $cursor: Cursor) {
+    team(name: $name) {
+        id
+        members(first: $first, after: $cursor) {
+            edges {
+                cursor
+                node {
+                    login
+                }
+            }
+            pageInfo {
+                hasNextPage
+            }
+        }
+    }
+}`, "\n", "")
+
+type LoginConnection struct {
+	Edges []struct {
+		Cursor string `json:"cursor"`
+		Node *struct {
+			Login string `json:"login"`
+		} `json:"node"` // Null for accounts that were deleted
+	} `json:"edges"`
+	Page_info struct {
+		Has_next_page bool `json:"hasNextPage"`
+	} `json:"pageInfo"`
+}
+
+type FollowsData struct {
+	User *struct {
+		Id      string          `json:"id"`
+		Follows LoginConnection `json:"follows"`
+	} `json:"user"`
+}
+
+type TeamData struct {
+	Team *struct {
+		Id      string          `json:"id"`
+		Members LoginConnection `json:"members"`
+	} `json:"team"`
+}
+
+// Logins of the channels `login` follows, if they are public
+func Graph_follows(login string) ([]string, error) {
+	return page_logins(func(cursor string) (LoginConnection, error) {
+		var data FollowsData
+		if _, err := Gql(context.TODO(), GqlOperation{
+			Operation_name: "follows",
+			Variables: map[string]any{"login": login, "first": IMPORT_PAGE_SIZE, "cursor": null_if_empty(cursor)},
+			Query: FOLLOWS_GRAPHQL_QUERY,
+		}, &data, fmt.Sprintf("graph-follows-%s-%s", login, cursor)); err != nil {
+			return LoginConnection{}, err
+		}
+		if data.User == nil {
+			return LoginConnection{}, ErrMissing{message: "User " + login + " does not exist"}
+		}
+		return data.User.Follows, nil
+	})
+}
+
+func Graph_team(name string) ([]string, error) {
+	return page_logins(func(cursor string) (LoginConnection, error) {
+		var data TeamData
+		if _, err := Gql(context.TODO(), GqlOperation{
+			Operation_name: "team",
+			Variables: map[string]any{"name": name, "first": IMPORT_PAGE_SIZE, "cursor": null_if_empty(cursor)},
+			Query: TEAM_GRAPHQL_QUERY,
+		}, &data, fmt.Sprintf("graph-team-%s-%s", name, cursor)); err != nil {
+			return LoginConnection{}, err
+		}
+		if data.Team == nil {
+			return LoginConnection{}, ErrMissing{message: "Team " + name + " does not exist"}
+		}
+		return data.Team.Members, nil
+	})
+}
+
+func page_logins(fetch func(cursor string) (LoginConnection, error)) ([]string, error) {
+	var logins []string
+	cursor := ""
+	for range IMPORT_MAX_PAGES {
+		page, err := fetch(cursor)
+		if err != nil {
+			return logins, err
+		}
+		for _, edge := range page.Edges {
+			if edge.Node != nil && edge.Node.Login != "" {
+				logins = append(logins, edge.Node.Login)
+			}
+			cursor = edge.Cursor
+		}
+		if !page.Page_info.Has_next_page || len(page.Edges) == 0 {
+			return logins, nil
+		}
+	}
+	L_ERROR.Printf("Stopped after %d pages, ignoring the rest", IMPORT_MAX_PAGES)
+	return logins, nil
+}
@@ -0,0 +1,70 @@
+package src
+
+import (
+	"fmt"
+	"testing"
+
+	a "github.com/yueleshia/streamsurf/src/testify"
+)
+
+//run: go test -v
+
+func TestImportPages(t *testing.T) {
+	pages := []string{
+		`{"user": {"id": "1", "follows": {"edges": [{"cursor": "c1", "node": {"login": "a"}}, {"cursor": "c2", "node": null}], "pageInfo": {"hasNextPage": true}}}}`,
+		`{"user": {"id": "1", "follows": {"edges": [{"cursor": "c3", "node": {"login": "b"}}], "pageInfo": {"hasNextPage": false}}}}`,
+	}
+	var cursors []string
+	logins, err := page_logins(func(cursor string) (LoginConnection, error) {
+		cursors = append(cursors, cursor)
+		var data FollowsData
+		a.AssertEqual(t, nil, Decode_json("test.follows", []byte(pages[len(cursors) - 1]), &data))
+		return data.User.Follows, nil
+	})
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, []string{"a", "b"}, logins)
+	a.AssertEqual(t, []string{"", "c2"}, cursors)
+}
+
+func TestMergeImport(t *testing.T) {
+	file := []ChannelEntry{
+		Parse_channel_entry("twitch:hand"),
+		Parse_channel_entry("twitch:old from=user:me"),
+		Parse_channel_entry("twitch:kept from=user:me"),
+		Parse_channel_entry("twitch:other from=team:x"),
+	}
+	is_built_in := func(login string) bool { return login == "embedded" }
+
+	merged, diff := Merge_import(file, is_built_in, "user:me", []string{"kept", "hand", "embedded", "new", "new", "other"})
+	a.AssertEqual(t, []string{"new"}, diff.Added)
+	a.AssertEqual(t, []string{"old"}, diff.Removed)
+
+	lines := make([]string, len(merged))
+	for i, x := range merged {
+		lines[i] = x.String()
+	}
+	a.AssertEqual(t, []string{
+		"twitch:hand",
+		"twitch:kept from=user:me",
+		"twitch:other from=team:x",
+		"twitch:new from=user:me",
+	}, lines)
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	a.AssertEqual(t, nil, Save_follow_file(merged))
+	followed, err := Load_follow_file()
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, "twitch:hand\ntwitch:kept from=user:me\ntwitch:other from=team:x\ntwitch:new from=user:me\n", followed)
+}
+
+func TestImportLimit(t *testing.T) {
+	logins := make([]string, MAX_CHANNELS)
+	for i := range logins {
+		logins[i] = fmt.Sprintf("user%d", i)
+	}
+	merged, diff := Merge_import(nil, func(string) bool { return false }, "user:me", logins)
+	a.AssertEqual(t, MAX_CHANNELS, len(diff.Added))
+	a.AssertEqual(t, nil, Check_channel_limit(0, merged))
+	a.AssertEqual(t, true, Check_channel_limit(1, merged) != nil)
+}
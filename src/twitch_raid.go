@@ -0,0 +1,96 @@
+package src
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// activeRaid is only set during the countdown before a raid goes through, so
+// this has to be asked while the channel is still live, and more often than a
+// refresh. `stream` tells the raid going through apart from it being called off.
+// @VOLATILE: This is what the raid banner of twitch.tv reads, it is not a
+//            documented API. A failure only means we show no raid.
+var RAID_GRAPHQL_QUERY = strings.ReplaceAll(`query raid($login: String!) {
+    user(login: $login) {
+        id
+        stream {
+            id
+        }
+        activeRaid {
+            id
+            targetLogin
+            targetDisplayName
+        }
+    }
+}`, "\n", "")
+
+type RaidData struct {
+	User *struct {
+		Id string `json:"id"`
+		Stream *struct {
+			Id string `json:"id"`
+		} `json:"stream"`
+		Active_raid *struct {
+			Id                  string `json:"id"`
+			Target_login        string `json:"targetLogin"`
+			Target_display_name string `json:"targetDisplayName"`
+		} `json:"activeRaid"`
+	} `json:"user"`
+}
+
+type Raid struct {
+	Channel             string
+	Target              string // Login, "" if the channel did not raid
+	Target_display_name string
+	Is_live             bool // False once the stream ended, with or without the raid
+	Time                time.Time // When we noticed, twitch does not say. The follow screen moves it to when the stream ended
+}
+
+// The i-th raid and error belong to channels[i]
+func Graph_raids(channels []string, now time.Time) ([]Raid, []error) {
+	raids := make([]Raid, len(channels))
+	errs := make([]error, len(channels))
+
+	batch_size := max(BATCH_SIZE, 1)
+	for start := 0; start < len(channels); start += batch_size {
+		batch := channels[start:min(start + batch_size, len(channels))]
+		operations := make([]GqlOperation, len(batch))
+		for i, channel := range batch {
+			operations[i] = GqlOperation{
+				Operation_name: "raid",
+				Variables: map[string]any{"login": channel},
+				Query: RAID_GRAPHQL_QUERY,
+			}
+		}
+
+		responses, err := Gql_batch(context.TODO(), operations, batch_cache_id("graph-raid", batch))
+		for i, channel := range batch {
+			raids[start + i] = Raid{Channel: channel}
+			if err != nil {
+				errs[start + i] = err
+				continue
+			}
+			var data RaidData
+			if err := responses[i].Decode(&data); err != nil {
+				errs[start + i] = err
+				continue
+			}
+			raids[start + i], errs[start + i] = parse_raid_query(channel, data, now)
+		}
+	}
+	return raids, errs
+}
+
+func parse_raid_query(channel string, data RaidData, now time.Time) (Raid, error) {
+	raid := Raid{Channel: channel, Time: now}
+	if data.User == nil {
+		return raid, ErrMissing{message: "Channel " + channel + " does not exist"}
+	}
+	raid.Is_live = data.User.Stream != nil
+	if x := data.User.Active_raid; x != nil {
+		raid.Target = x.Target_login
+		raid.Target_display_name = x.Target_display_name
+	}
+	return raid, nil
+}
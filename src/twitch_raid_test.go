@@ -0,0 +1,31 @@
+package src
+
+import (
+	"testing"
+	"time"
+
+	a "github.com/yueleshia/streamsurf/src/testify"
+)
+
+//run: go test -v
+
+func TestRaidParse(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	parse := func(response string) (Raid, error) {
+		var data RaidData
+		a.AssertEqual(t, nil, Decode_json("test.raid", []byte(response), &data))
+		return parse_raid_query("foo", data, now)
+	}
+
+	raid, err := parse(`{"user": {"id": "1", "stream": {"id": "s"}, "activeRaid": {"id": "r", "targetLogin": "bar", "targetDisplayName": "Bar"}}}`)
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, Raid{Channel: "foo", Target: "bar", Target_display_name: "Bar", Is_live: true, Time: now}, raid)
+
+	raid, err = parse(`{"user": {"id": "1", "stream": null, "activeRaid": null}}`)
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, "", raid.Target)
+	a.AssertEqual(t, false, raid.Is_live)
+
+	_, err = parse(`{"user": null}`)
+	a.AssertEqual(t, true, err != nil)
+}
@@ -0,0 +1,256 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Segments of recurring streams come back once per occurrence, so we only need
+// to ask for the window we want to show
+const SCHEDULE_WINDOW = 14 * 24 * time.Hour
+
+var SCHEDULE_GRAPHQL_QUERY = strings.ReplaceAll(`query schedule($login: String!, $startAt: Time, $endAt: Time) {
+    user(login: $login) {
+        id
+        channel {
+            schedule {
+                segments(startAt: $startAt, endAt: $endAt) {
+                    id
+                    startAt
+                    endAt
+                    title
+                    canceledUntil
+                    categories {
+                        name
+                    }
+                }
+                vacation {
+                    startAt
+                    endAt
+                }
+            }
+        }
+    }
+}`, "\n", "")
+
+type ScheduleData struct {
+	User *struct {
+		Id string `json:"id"`
+		Channel struct {
+			Schedule *struct {
+				Segments []struct {
+					Id             string  `json:"id"`
+					Start_at       string  `json:"startAt"`
+					End_at         *string `json:"endAt"`
+					Title          string  `json:"title"`
+					Canceled_until *string `json:"canceledUntil"`
+					Categories []struct {
+						Name string `json:"name"`
+					} `json:"categories"`
+				} `json:"segments"`
+				Vacation *struct {
+					Start_at string `json:"startAt"`
+					End_at   string `json:"endAt"`
+				} `json:"vacation"`
+			} `json:"schedule"`
+		} `json:"channel"`
+	} `json:"user"`
+}
+
+// Vacations are segments too, so that they sort with everything else
+type ScheduleSegment struct {
+	Id           string
+	Channel      string
+	Title        string
+	Category     string
+	Start        time.Time
+	End          time.Time // Zero if the streamer did not say
+	Is_cancelled bool
+	Is_vacation  bool
+}
+
+type ScheduleVariables struct {
+	Login    string `json:"login"`
+	Start_at string `json:"startAt"`
+	End_at   string `json:"endAt"`
+}
+
+// The i-th list of segments and error belong to channels[i]
+func Graph_schedules(channels []string, now time.Time) ([][]ScheduleSegment, []error) {
+	segments := make([][]ScheduleSegment, len(channels))
+	errs := make([]error, len(channels))
+
+	batch_size := max(BATCH_SIZE, 1)
+	for start := 0; start < len(channels); start += batch_size {
+		batch := channels[start:min(start + batch_size, len(channels))]
+		operations := make([]GqlOperation, len(batch))
+		for i, channel := range batch {
+			operations[i] = GqlOperation{
+				Operation_name: "schedule",
+				Variables: ScheduleVariables{
+					Login:    channel,
+					Start_at: now.UTC().Format(time.RFC3339),
+					End_at:   now.Add(SCHEDULE_WINDOW).UTC().Format(time.RFC3339),
+				},
+				Query: SCHEDULE_GRAPHQL_QUERY,
+			}
+		}
+
+		responses, err := Gql_batch(context.TODO(), operations, batch_cache_id("graph-schedule", batch))
+		for i, channel := range batch {
+			if err != nil {
+				errs[start + i] = err
+				continue
+			}
+			var data ScheduleData
+			if err := responses[i].Decode(&data); err != nil {
+				errs[start + i] = err
+				continue
+			}
+			segments[start + i], errs[start + i] = parse_schedule_query(channel, data)
+		}
+	}
+	return segments, errs
+}
+
+func parse_schedule_query(channel string, data ScheduleData) ([]ScheduleSegment, error) {
+	if data.User == nil {
+		return nil, ErrMissing{message: "Channel " + channel + " does not exist"}
+	}
+	schedule := data.User.Channel.Schedule
+	if schedule == nil {
+		return nil, nil
+	}
+
+	var segments []ScheduleSegment
+	for _, x := range schedule.Segments {
+		segment := ScheduleSegment{Id: x.Id, Channel: channel, Title: x.Title}
+		if t, err := time.Parse(time.RFC3339, x.Start_at); err != nil {
+			return segments, err
+		} else {
+			segment.Start = t
+		}
+		if x.End_at != nil {
+			if t, err := time.Parse(time.RFC3339, *x.End_at); err != nil {
+				return segments, err
+			} else {
+				segment.End = t
+			}
+		}
+		// Cancelling a recurring segment cancels every occurrence until then
+		if x.Canceled_until != nil {
+			if t, err := time.Parse(time.RFC3339, *x.Canceled_until); err != nil {
+				return segments, err
+			} else {
+				segment.Is_cancelled = !t.Before(segment.Start)
+			}
+		}
+		if len(x.Categories) > 0 {
+			segment.Category = x.Categories[0].Name
+		}
+		segments = append(segments, segment)
+	}
+
+	if x := schedule.Vacation; x != nil {
+		vacation := ScheduleSegment{Id: "vacation", Channel: channel, Title: "Vacation", Is_vacation: true}
+		var err error
+		if vacation.Start, err = time.Parse(time.RFC3339, x.Start_at); err != nil {
+			return segments, err
+		}
+		if vacation.End, err = time.Parse(time.RFC3339, x.End_at); err != nil {
+			return segments, err
+		}
+		segments = append(segments, vacation)
+	}
+	return segments, nil
+}
+
+// Every channel's segments in one list, earliest first
+func Merge_schedules(schedules [][]ScheduleSegment) []ScheduleSegment {
+	var merged []ScheduleSegment
+	for _, segments := range schedules {
+		merged = append(merged, segments...)
+	}
+	slices.SortStableFunc(merged, func(a, b ScheduleSegment) int {
+		return a.Start.Compare(b.Start)
+	})
+	return merged
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// iCalendar, see RFC 5545
+
+const ICS_TIME_FORMAT = "20060102T150405Z"
+
+// Segments without an end time are given this length, calendars need one
+const ICS_DEFAULT_DURATION = 2 * time.Hour
+
+func Write_ics(output io.Writer, segments []ScheduleSegment, now time.Time) error {
+	var builder strings.Builder
+	line := func(key string, value string) {
+		ics_fold(&builder, key + ":" + value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//streamsurf//schedule//EN")
+	line("X-WR-CALNAME", "Twitch schedule")
+	for _, x := range segments {
+		end := x.End
+		if end.IsZero() {
+			end = x.Start.Add(ICS_DEFAULT_DURATION)
+		}
+
+		line("BEGIN", "VEVENT")
+		// Occurrences of a recurring segment share the segment ID
+		line("UID", fmt.Sprintf("%s-%s-%d@streamsurf", x.Channel, x.Id, x.Start.Unix()))
+		line("DTSTAMP", now.UTC().Format(ICS_TIME_FORMAT))
+		line("DTSTART", x.Start.UTC().Format(ICS_TIME_FORMAT))
+		line("DTEND", end.UTC().Format(ICS_TIME_FORMAT))
+		line("URL", "https://www.twitch.tv/" + x.Channel)
+		if x.Is_vacation {
+			line("SUMMARY", ics_escape(x.Channel + " is on vacation"))
+			line("TRANSP", "TRANSPARENT")
+		} else {
+			line("SUMMARY", ics_escape(x.Channel + ": " + x.Title))
+			if x.Category != "" {
+				line("CATEGORIES", ics_escape(x.Category))
+				line("DESCRIPTION", ics_escape(x.Category))
+			}
+		}
+		if x.Is_cancelled {
+			line("STATUS", "CANCELLED")
+		} else {
+			line("STATUS", "CONFIRMED")
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+
+	_, err := io.WriteString(output, builder.String())
+	return err
+}
+
+func ics_escape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(text)
+}
+
+// Lines are at most 75 octets, continued on the next line after a space
+func ics_fold(builder *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		// Do not split a UTF-8 sequence
+		for cut > 0 && line[cut] & 0xC0 == 0x80 {
+			cut -= 1
+		}
+		builder.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74 // The leading space counts
+	}
+	builder.WriteString(line + "\r\n")
+}
@@ -0,0 +1,67 @@
+package src
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	a "github.com/yueleshia/streamsurf/src/testify"
+)
+
+//run: go test -v
+
+func TestScheduleParse(t *testing.T) {
+	response := `{"user": {"id": "1", "channel": {"schedule": {
+		"segments": [
+			{"id": "a", "startAt": "2025-01-06T18:00:00Z", "endAt": "2025-01-06T20:00:00Z", "title": "Speedrun",
+				"canceledUntil": null, "categories": [{"name": "Elden Ring"}]},
+			{"id": "b", "startAt": "2025-01-07T18:00:00Z", "endAt": null, "title": "Chat",
+				"canceledUntil": "2025-01-08T00:00:00Z", "categories": []}
+		],
+		"vacation": {"startAt": "2025-01-10T00:00:00Z", "endAt": "2025-01-20T00:00:00Z"}
+	}}}}`
+	var data ScheduleData
+	a.AssertEqual(t, nil, Decode_json("test.schedule", []byte(response), &data))
+
+	segments, err := parse_schedule_query("foo", data)
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, 3, len(segments))
+	a.AssertEqual(t, "Elden Ring", segments[0].Category)
+	a.AssertEqual(t, false, segments[0].Is_cancelled)
+	a.AssertEqual(t, true, segments[1].Is_cancelled)
+	a.AssertEqual(t, true, segments[1].End.IsZero())
+	a.AssertEqual(t, true, segments[2].Is_vacation)
+
+	// No schedule is not an error
+	data.User.Channel.Schedule = nil
+	segments, err = parse_schedule_query("bar", data)
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, 0, len(segments))
+
+	merged := Merge_schedules([][]ScheduleSegment{
+		{{Channel: "foo", Start: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)}},
+		{{Channel: "bar", Start: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}},
+	})
+	a.AssertEqual(t, "bar", merged[0].Channel)
+}
+
+func TestIcs(t *testing.T) {
+	start := time.Date(2025, 1, 6, 18, 0, 0, 0, time.UTC)
+	var builder strings.Builder
+	a.AssertEqual(t, nil, Write_ics(&builder, []ScheduleSegment{
+		{Id: "a", Channel: "foo", Title: "Chess, checkers; more", Category: "Chess", Start: start},
+		{Id: "b", Channel: "foo", Title: strings.Repeat("long ", 20), Start: start, Is_cancelled: true},
+	}, start))
+	ics := builder.String()
+
+	a.AssertEqual(t, true, strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\n"))
+	a.AssertEqual(t, true, strings.HasSuffix(ics, "END:VCALENDAR\r\n"))
+	a.AssertEqual(t, true, strings.Contains(ics, "SUMMARY:foo: Chess\\, checkers\\; more\r\n"))
+	a.AssertEqual(t, true, strings.Contains(ics, "DTSTART:20250106T180000Z\r\nDTEND:20250106T200000Z\r\n"))
+	a.AssertEqual(t, true, strings.Contains(ics, "STATUS:CANCELLED\r\n"))
+	for line := range strings.SplitSeq(ics, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Line longer than 75 octets: %q", line)
+		}
+	}
+}
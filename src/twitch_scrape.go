@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"regexp"
 	"strings"
 	"time"
 
@@ -65,16 +66,50 @@ func read_twitch_frontend_packet(input io.ReadCloser) ([]byte, error) {
 	return nil, ErrMissing { message: "Packet not found" }
 }
 
+var client_id_regexp = regexp.MustCompile(`clientId\s*[=:]\s*"([a-z0-9]{30})"`)
+
+// Same idea as read_twitch_frontend_packet, but the client ID is set in an
+// inline script
+func read_twitch_client_id(input io.Reader) (string, error) {
+	z := html.NewTokenizer(input)
+	is_script := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return "", ErrMissing{message: "Client ID not found"}
+			}
+			return "", z.Err()
+		case html.StartTagToken:
+			tag_name, _ := z.TagName()
+			is_script = bytes.Equal(tag_name, []byte("script"))
+		case html.EndTagToken:
+			is_script = false
+		case html.TextToken:
+			if is_script {
+				if match := client_id_regexp.FindSubmatch(z.Text()); match != nil {
+					return string(match[1]), nil
+				}
+			}
+		}
+	}
+}
 
 // You probably have to run this several times since it stochastically curls nothing
-func Scrape_vods(channel string) VideoPacket {
+// `broadcast_type` is one of BROADCAST_*, or "" for all of them
+func Scrape_vods(channel string, broadcast_type string) VideoPacket {
 	Assert(strings.Index(channel, "/") == -1)
 	videos := [10]Video{}
 
-	body, err := Request(context.TODO(), "GET", nil, nil, "https://twitch.tv/" + channel + "/videos", fmt.Sprintf("scrape-%s-videos", channel))
+	target := "https://twitch.tv/" + channel + "/videos"
+	if broadcast_type != "" {
+		// Same as clicking the filter on the videos page, e.g. ?filter=archives
+		target += "?filter=" + Broadcast_type_name(broadcast_type) + "s"
+	}
+	body, err := Request(context.TODO(), "GET", nil, nil, target, fmt.Sprintf("scrape-%s-videos-%s", channel, broadcast_type))
 	
 	if err != nil {
-		return VideoPacket{nil, false, err}
+		return VideoPacket{Vids: nil, Live: false, Err: err}
 	}
 	ret, err := func () ([]Video, error) {
 		var live_data []byte
@@ -88,7 +123,7 @@ func Scrape_vods(channel string) VideoPacket {
 
 		type GraphQL struct {
 			Context string            `json:"@context"`
-			Graph   [2]json.RawMessage `json:"@graph"`
+			Graph   []json.RawMessage `json:"@graph"`
 		}
 
 		type VideoObject struct {
@@ -110,27 +145,29 @@ func Scrape_vods(channel string) VideoPacket {
 
 		idx := 0
 		var step1 GraphQL
-		{
-			dec := json.NewDecoder(bytes.NewBuffer(live_data))
-			dec.DisallowUnknownFields()
-			if err := dec.Decode(&step1); err != nil {
-				return nil, err
-			}
+		if err := Decode_json("scrape.videos", live_data, &step1); err != nil {
+			return nil, err
 		}
 
+		// @graph holds a mix of types, we only want the list of videos
 		var step2 ItemList
 		{
-			var err error = nil
+			is_found := false
 			for _, x := range step1.Graph {
-				dec := json.NewDecoder(bytes.NewBuffer(x))
-				dec.DisallowUnknownFields()
-				err = dec.Decode(&step2)
-				if err == nil {
-					break
+				var probe struct {
+					Type string `json:"@type"`
+				}
+				if err := json.Unmarshal(x, &probe); err != nil || probe.Type != "ItemList" {
+					continue
+				}
+				if err := Decode_json("scrape.videos.ItemList", x, &step2); err != nil {
+					return videos[:0], err
 				}
+				is_found = true
+				break
 			}
-			if err != nil {
-				return videos[:0], err
+			if !is_found {
+				return videos[:0], ErrMissing{message: "No list of videos on the videos page of " + channel}
 			}
 		}
 		// Reverse so that our QUEUE overwrites older VODs
@@ -173,6 +210,9 @@ func Scrape_vods(channel string) VideoPacket {
 				Is_live: false,
 				Url: x.Url,
 				Chapters: []Chapter{},
+				Broadcast_type: broadcast_type,
+				Description: x.Description,
+				View_count: interaction_count(x.Stats),
 			}
 			idx += 1
 			if idx >= 10 {
@@ -182,13 +222,30 @@ func Scrape_vods(channel string) VideoPacket {
 		return videos[:idx], nil
 	}()
 	if err := body.Close(); err != nil {
-		return VideoPacket{nil, false, err}
+		return VideoPacket{Vids: nil, Live: false, Err: err}
 	}
-	return VideoPacket{ret, false, err}
+	return VideoPacket{Vids: ret, Live: false, Err: err}
 }
 
 
 
+// schema.org lets interactionStatistic be a single InteractionCounter or a list
+// of them. We only care about the views.
+func interaction_count(raw json.RawMessage) int {
+	type InteractionCounter struct {
+		User_interaction_count int `json:"userInteractionCount"`
+	}
+	var one InteractionCounter
+	if err := json.Unmarshal(raw, &one); err == nil {
+		return one.User_interaction_count
+	}
+	var many []InteractionCounter
+	if err := json.Unmarshal(raw, &many); err == nil && len(many) > 0 {
+		return many[0].User_interaction_count
+	}
+	return 0
+}
+
 func Scrape_live_status(channel string) VideoPacket {
 	Assert(strings.Index(channel, "/") == -1)
 	offline_vid := Video {
@@ -198,7 +255,7 @@ func Scrape_live_status(channel string) VideoPacket {
 	channel_url := "https://twitch.tv/" + channel
 	body, err := Request(context.TODO(), "GET", nil, nil, channel_url, fmt.Sprintf("scrape-%s", channel))
 	if err != nil {
-		return VideoPacket{[]Video{offline_vid}, true, err}
+		return VideoPacket{Vids: []Video{offline_vid}, Live: true, Err: err}
 	}
 	ret, err := func () (Video, error) {
 		var live_data []byte
@@ -231,18 +288,18 @@ func Scrape_live_status(channel string) VideoPacket {
 		}
 		type GraphQL struct {
 			Context string `json:"@context"`
-			Graph   [1]Obj `json:"@graph"`
+			Graph   []Obj  `json:"@graph"`
 		}
 
 		var x GraphQL
-		dec := json.NewDecoder(bytes.NewBuffer(live_data))
-		dec.DisallowUnknownFields()
-		if err := dec.Decode(&x); err != nil {
+		if err := Decode_json("scrape.live", live_data, &x); err != nil {
 			return offline_vid, err
 		}
 		//fmt.Println(x)
 
-		// @TODO: error check around len
+		if len(x.Graph) == 0 {
+			return offline_vid, ErrMissing { message: channel + " is not live" }
+		}
 		node := x.Graph[0]
 		var start, close time.Time
 		//"2025-11-12T15:06:12Z"
@@ -268,7 +325,49 @@ func Scrape_live_status(channel string) VideoPacket {
 		}, nil
 	}()
 	if err := body.Close(); err != nil {
-		return VideoPacket{[]Video{offline_vid}, true, err}
+		return VideoPacket{Vids: []Video{offline_vid}, Live: true, Err: err}
+	}
+	return VideoPacket{Vids: []Video{ret}, Live: true, Err: err}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Provider
+
+// Slower and less complete than TwitchGraph, but does not depend on CLIENT_ID
+type TwitchScrape struct{}
+
+// The videos page has no pagination, so every page after the first is empty
+func (TwitchScrape) Vods(channel string, cursor string, broadcast_type string) VideoPacket {
+	if cursor != "" {
+		return VideoPacket{Channel: channel, Cursor: cursor}
 	}
-	return VideoPacket{[]Video{ret}, true, err}
+	packet := Scrape_vods(channel, broadcast_type)
+	packet.Channel = channel
+	packet.Stamp_backend("scrape")
+	return packet
+}
+
+func (TwitchScrape) Live_status(channel string) VideoPacket {
+	packet := Scrape_live_status(channel)
+	packet.Channel = channel
+	// Offline is a valid status
+	if _, ok := packet.Err.(ErrMissing); ok {
+		packet.Err = nil
+	}
+	packet.Stamp_backend("scrape")
+	return packet
+}
+
+func (TwitchScrape) Channel_info(channel string) (ChannelInfo, error) {
+	return ChannelInfo{}, ErrMissing{message: "Channel info is not available when scraping"}
+}
+
+func (TwitchScrape) Playback_url(video Video) (string, error) {
+	return video.Url, nil
+}
+
+// The playback token is only ever handed out over GQL, so this is the same
+// request as TwitchGraph
+func (TwitchScrape) Playback_variants(video Video) ([]Variant, error) {
+	return Resolve_variants(video)
 }
@@ -0,0 +1,121 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// What the search bar on twitch.tv suggests for channels
+var SEARCH_GRAPHQL_QUERY = strings.ReplaceAll(`query search($query: String!, $limit: Int) {
+    searchUsers(userQuery: $query, first: $limit) {
+        edges {
+            node {
+                id
+                login
+                displayName
+                description
+                profileImageURL(width: 50)
+                followers {
+                    totalCount
+                }
+                stream {
+                    createdAt
+                    viewersCount
+                    game {
+                        name
+                    }
+                }
+                broadcastSettings {
+                    title
+                }
+            }
+        }
+    }
+}`, "\n", "")
+
+type SearchData struct {
+	Search_users struct {
+		Edges []struct {
+			Node struct {
+				Id           string `json:"id"`
+				Login        string `json:"login"`
+				Display_name string `json:"displayName"`
+				Description  string `json:"description"`
+				Profile_URL  string `json:"profileImageURL"`
+				Followers struct {
+					Total_count int `json:"totalCount"`
+				} `json:"followers"`
+				Stream *struct {
+					Created_at    string `json:"createdAt"`
+					Viewers_count int    `json:"viewersCount"`
+					Game *struct {
+						Name string `json:"name"`
+					} `json:"game"`
+				} `json:"stream"`
+				Broadcast_settings struct {
+					Title string `json:"title"`
+				} `json:"broadcastSettings"`
+			} `json:"node"`
+		} `json:"edges"`
+	} `json:"searchUsers"`
+}
+
+type SearchResult struct {
+	Channel   ChannelInfo
+	Followers int
+	Live      Video // Is_live is false when the channel is offline
+}
+
+func Graph_search(text string) ([]SearchResult, error) {
+	var data SearchData
+	if _, err := Gql(context.TODO(), GqlOperation{
+		Operation_name: "search",
+		Variables: map[string]any{"query": text, "limit": PAGE_SIZE},
+		Query: SEARCH_GRAPHQL_QUERY,
+	}, &data, fmt.Sprintf("graph-search-%s", text)); err != nil {
+		return nil, err
+	}
+	return parse_search_query(data)
+}
+
+func parse_search_query(data SearchData) ([]SearchResult, error) {
+	results := make([]SearchResult, 0, len(data.Search_users.Edges))
+	for _, edge := range data.Search_users.Edges {
+		x := edge.Node
+		result := SearchResult{
+			Channel: ChannelInfo{
+				Id:           x.Id,
+				Login:        x.Login,
+				Display_name: x.Display_name,
+				Description:  x.Description,
+				Avatar_URL:   x.Profile_URL,
+			},
+			Followers: x.Followers.Total_count,
+			Live: Video{Channel: x.Login, Display_name: x.Display_name, Avatar_URL: x.Profile_URL},
+		}
+		if x.Stream != nil {
+			start, err := time.Parse(time.RFC3339, x.Stream.Created_at)
+			if err != nil {
+				return results, err
+			}
+			game := ""
+			if x.Stream.Game != nil {
+				game = x.Stream.Game.Name
+			}
+			duration := time.Now().Sub(start)
+			result.Live.Title = x.Broadcast_settings.Title
+			result.Live.Start_time = start
+			result.Live.Duration = duration
+			result.Live.Is_live = true
+			result.Live.Url = "https://www.twitch.tv/" + x.Login
+			result.Live.Chapters = []Chapter{{Name: game, Duration: duration}}
+			result.Live.Game = game
+			result.Live.Viewers = x.Stream.Viewers_count
+			result.Live.Peak_viewers = x.Stream.Viewers_count
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
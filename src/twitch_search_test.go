@@ -0,0 +1,33 @@
+package src
+
+import (
+	"testing"
+
+	a "github.com/yueleshia/streamsurf/src/testify"
+)
+
+//run: go test -v
+
+func TestSearchParse(t *testing.T) {
+	response := `{"searchUsers": {"edges": [
+		{"node": {"id": "1", "login": "foo", "displayName": "Foo", "followers": {"totalCount": 1200},
+			"stream": {"createdAt": "2025-01-01T00:00:00Z", "viewersCount": 30, "game": {"name": "Chess"}},
+			"broadcastSettings": {"title": "Blitz"}}},
+		{"node": {"id": "2", "login": "foobar", "displayName": "FooBar", "followers": {"totalCount": 5},
+			"stream": null, "broadcastSettings": {"title": "Old title"}}}
+	]}}`
+	var data SearchData
+	a.AssertEqual(t, nil, Decode_json("test.search", []byte(response), &data))
+
+	results, err := parse_search_query(data)
+	a.AssertEqual(t, nil, err)
+	a.AssertEqual(t, 2, len(results))
+	a.AssertEqual(t, "foo", results[0].Channel.Login)
+	a.AssertEqual(t, 1200, results[0].Followers)
+	a.AssertEqual(t, true, results[0].Live.Is_live)
+	a.AssertEqual(t, "Blitz", results[0].Live.Title)
+	a.AssertEqual(t, "Chess", results[0].Live.Game)
+	a.AssertEqual(t, 30, results[0].Live.Viewers)
+	a.AssertEqual(t, false, results[1].Live.Is_live)
+	a.AssertEqual(t, "", results[1].Live.Title)
+}
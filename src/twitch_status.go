@@ -0,0 +1,104 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Why a followed channel has no videos. The videos query gives a null user both
+// for a login nobody has and for a suspended account, and a renamed channel
+// looks just like a deleted one unless we ask by ID.
+
+const (
+	CHANNEL_ACTIVE = iota
+	CHANNEL_MISSING   // No account with that login or ID, e.g. deleted
+	CHANNEL_SUSPENDED // The account exists but twitch does not show it, e.g. banned
+	CHANNEL_RENAMED   // Same ID, new login
+)
+
+// @VOLATILE: lookupType ALL is what twitch.tv uses to show the page of a banned
+//            channel, the default (ACTIVE) returns null for them
+var STATUS_BY_ID_GRAPHQL_QUERY = strings.ReplaceAll(`query status($id: ID!) {
+    all: user(id: $id, lookupType: ALL) {
+        id
+        login
+    }
+    active: user(id: $id) {
+        id
+    }
+}`, "\n", "")
+
+var STATUS_BY_LOGIN_GRAPHQL_QUERY = strings.ReplaceAll(`query status($login: String!) {
+    all: user(login: $login, lookupType: ALL) {
+        id
+        login
+    }
+    active: user(login: $login) {
+        id
+    }
+}`, "\n", "")
+
+type ChannelStatusData struct {
+	All *struct {
+		Id    string `json:"id"`
+		Login string `json:"login"`
+	} `json:"all"`
+	Active *struct {
+		Id string `json:"id"`
+	} `json:"active"`
+}
+
+type ChannelStatus struct {
+	Channel string // The login we follow it by
+	Id      string
+	State   int    // One of CHANNEL_*
+	Login   string // The login now, differs from Channel when renamed
+}
+
+// e.g. "renamed to bar", "" when active
+func (self ChannelStatus) String() string {
+	switch self.State {
+	case CHANNEL_MISSING:   return "does not exist"
+	case CHANNEL_SUSPENDED: return "suspended"
+	case CHANNEL_RENAMED:   return "renamed to " + self.Login
+	}
+	return ""
+}
+
+// Asks by ID if the entry has one, which is the only way to notice a rename
+func Graph_channel_status(entry ChannelEntry) (ChannelStatus, error) {
+	operation := GqlOperation{
+		Operation_name: "status",
+		Variables: map[string]any{"login": entry.Login},
+		Query: STATUS_BY_LOGIN_GRAPHQL_QUERY,
+	}
+	if entry.Id != "" {
+		operation.Variables = map[string]any{"id": entry.Id}
+		operation.Query = STATUS_BY_ID_GRAPHQL_QUERY
+	}
+
+	var data ChannelStatusData
+	if _, err := Gql(context.TODO(), operation, &data, fmt.Sprintf("graph-status-%s-%s", entry.Login, entry.Id)); err != nil {
+		return ChannelStatus{Channel: entry.Login, Id: entry.Id}, err
+	}
+	return parse_channel_status(entry, data), nil
+}
+
+func parse_channel_status(entry ChannelEntry, data ChannelStatusData) ChannelStatus {
+	status := ChannelStatus{Channel: entry.Login, Id: entry.Id, Login: entry.Login}
+	switch {
+	case data.All == nil:
+		status.State = CHANNEL_MISSING
+		return status
+	case data.Active == nil:
+		status.State = CHANNEL_SUSPENDED
+	case !strings.EqualFold(data.All.Login, entry.Login):
+		status.State = CHANNEL_RENAMED
+	default:
+		status.State = CHANNEL_ACTIVE
+	}
+	status.Id = data.All.Id
+	status.Login = data.All.Login
+	return status
+}
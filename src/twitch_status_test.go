@@ -0,0 +1,44 @@
+package src
+
+import (
+	"testing"
+
+	a "github.com/yueleshia/streamsurf/src/testify"
+)
+
+//run: go test -v
+
+func TestChannelStatus(t *testing.T) {
+	parse := func(entry string, response string) ChannelStatus {
+		var data ChannelStatusData
+		a.AssertEqual(t, nil, Decode_json("test.status", []byte(response), &data))
+		return parse_channel_status(Parse_channel_entry(entry), data)
+	}
+
+	a.AssertEqual(t, ChannelStatus{Channel: "foo", Id: "1", State: CHANNEL_ACTIVE, Login: "foo"},
+		parse("foo", `{"all": {"id": "1", "login": "foo"}, "active": {"id": "1"}}`))
+	a.AssertEqual(t, ChannelStatus{Channel: "foo", Id: "1", State: CHANNEL_RENAMED, Login: "bar"},
+		parse("foo id=1", `{"all": {"id": "1", "login": "bar"}, "active": {"id": "1"}}`))
+	a.AssertEqual(t, "suspended", parse("foo", `{"all": {"id": "1", "login": "foo"}, "active": null}`).String())
+	a.AssertEqual(t, "does not exist", parse("foo", `{"all": null, "active": null}`).String())
+
+	// The videos query cannot tell these apart, so it only says the channel is missing
+	var data VideosData
+	a.AssertEqual(t, nil, Decode_json("test.videos", []byte(`{"user": null}`), &data))
+	packet, _, _ := parse_videos_query("foo", "", data)
+	_, is_missing := packet.Err.(ErrMissing)
+	a.AssertEqual(t, true, is_missing)
+}
+
+func TestRewriteEntries(t *testing.T) {
+	entries := []ChannelEntry{
+		Parse_channel_entry("foo"),
+		Parse_channel_entry("bar id=2 hide=upload"),
+		Parse_channel_entry("category:chess"),
+	}
+	changed := Rewrite_entries(entries, map[string]string{"bar": "baz", "chess": "go"})
+	a.AssertEqual(t, 1, changed)
+	a.AssertEqual(t, "twitch:foo", entries[0].String())
+	a.AssertEqual(t, "twitch:baz hide=upload id=2", entries[1].String())
+	a.AssertEqual(t, "category:chess", entries[2].String())
+}